@@ -12,6 +12,12 @@ type zapLogLevelController interface {
 	SetMinLevel(level lager.LogLevel)
 }
 
+// zapLogLevelGetter is an optional interface for log controllers
+// that can report their current minimum log level.
+type zapLogLevelGetter interface {
+	GetMinLevel() lager.LogLevel
+}
+
 // LagerAdapter is an adapter for the ReconfigurableSinkInterface to work with lager.LogLevel.
 type LagerAdapter struct {
 	Sink ReconfigurableSinkInterface
@@ -22,6 +28,17 @@ func (l *LagerAdapter) SetMinLevel(level lager.LogLevel) {
 	l.Sink.SetMinLevel(level)
 }
 
+// minLevelGetter returns the getter for the current log level of zapCtrl, if it has one.
+// A LagerAdapter supports reading the level when the sink it wraps does.
+func minLevelGetter(zapCtrl zapLogLevelController) (zapLogLevelGetter, bool) {
+	if adapter, ok := zapCtrl.(*LagerAdapter); ok {
+		getter, ok := adapter.Sink.(zapLogLevelGetter)
+		return getter, ok
+	}
+	getter, ok := zapCtrl.(zapLogLevelGetter)
+	return getter, ok
+}
+
 // normalizeLogLevel returns a single value that represents
 // various forms of the same input level. For example:
 // "0", "d", "debug", all of these represents debug log level.
@@ -43,26 +60,22 @@ func normalizeLogLevel(input string) string {
 }
 
 // validateAndNormalize does two things:
-// It validates the incoming request is HTTP type, uses POST method and has non-nil level specified.
-// It also normalizes the various forms of the same log level type. For ex: 0, d, debug are all same.
+// It validates the incoming request uses the POST or PUT method and has non-nil level specified.
+// It also normalizes the various forms of the same log level type. For ex: 0, d, debug are all same,
+// and levels with an offset such as "INFO+2" are written as Level.String writes them.
 func validateAndNormalize(w http.ResponseWriter, r *http.Request, level []byte) (string, error) {
-	if r.Method != http.MethodPost {
-		return "", errors.New("method not allowed, use POST")
-	}
-
-	if r.TLS != nil {
-		return "", errors.New("invalid scheme, https is not allowed")
+	if !isWriteMethod(r) {
+		return "", errors.New("method not allowed, use POST or PUT")
 	}
 
 	if len(level) == 0 {
 		return "", errors.New("log level cannot be empty")
 	}
 
-	input := strings.TrimSpace(string(level))
-	normalized := normalizeLogLevel(input)
-	if normalized == "" {
-		return "", errors.New("invalid log level: " + string(level))
+	parsed, err := ParseLevel(string(level))
+	if err != nil {
+		return "", err
 	}
 
-	return normalized, nil
+	return parsed.String(), nil
 }
@@ -0,0 +1,89 @@
+package debugserver
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	lager "code.cloudfoundry.org/lager/v3"
+)
+
+// WithLogger makes the debug server write one audit record per request to logger,
+// including the remote address, endpoint, parameters, response status and duration.
+func WithLogger(logger lager.Logger) Option {
+	return func(o *options) {
+		o.logger = logger.Session("debug-server")
+	}
+}
+
+type auditParamsKey struct{}
+
+// addAuditParams records endpoint specific parameters, such as the old and new log level,
+// in the audit record of the request. It does nothing when audit logging is disabled.
+func addAuditParams(r *http.Request, data lager.Data) {
+	params, ok := r.Context().Value(auditParamsKey{}).(lager.Data)
+	if !ok {
+		return
+	}
+	for k, v := range data {
+		params[k] = v
+	}
+}
+
+// audit wraps the whole handler, so requests refused by auth are recorded as well.
+func (o *options) audit(h http.Handler) http.Handler {
+	if o.logger == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		params := lager.Data{}
+		for k, v := range r.URL.Query() {
+			params[k] = strings.Join(v, ",")
+		}
+		r = r.WithContext(context.WithValue(r.Context(), auditParamsKey{}, params))
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r)
+
+		data := lager.Data{
+			"remote_addr": r.RemoteAddr,
+			"method":      r.Method,
+			"endpoint":    r.URL.Path,
+			"params":      params,
+			"status":      rec.status,
+			"duration":    time.Since(start).String(),
+		}
+		if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+			data["client_cert_subject"] = r.TLS.PeerCertificates[0].Subject.String()
+		}
+		o.logger.Info("request", data)
+	})
+}
+
+// statusRecorder remembers the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,145 @@
+package debugserver_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	cf_debug_server "code.cloudfoundry.org/debugserver"
+	lager "code.cloudfoundry.org/lager/v3"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Audit logging", func() {
+	var (
+		sink    *lager.ReconfigurableSink
+		logs    *bytes.Buffer
+		handler http.Handler
+	)
+
+	BeforeEach(func() {
+		sink = lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.INFO)
+		logs = &bytes.Buffer{}
+		logger := lager.NewLogger("test")
+		logger.RegisterSink(lager.NewWriterSink(logs, lager.DEBUG))
+		handler = cf_debug_server.Handler(sink, cf_debug_server.WithLogger(logger))
+	})
+
+	serve := func(method, target, body string) {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.RemoteAddr = "10.0.0.1:5555"
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	records := func() []lager.LogFormat {
+		var result []lager.LogFormat
+		scanner := bufio.NewScanner(logs)
+		for scanner.Scan() {
+			var record lager.LogFormat
+			Expect(json.Unmarshal(scanner.Bytes(), &record)).To(Succeed())
+			result = append(result, record)
+		}
+		return result
+	}
+
+	It("records log level changes with the old and new level", func() {
+		serve(http.MethodPost, "/log-level", "debug for=1h")
+
+		entries := records()
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Message).To(Equal("test.debug-server.request"))
+		Expect(entries[0].Data).To(HaveKeyWithValue("remote_addr", "10.0.0.1:5555"))
+		Expect(entries[0].Data).To(HaveKeyWithValue("method", "POST"))
+		Expect(entries[0].Data).To(HaveKeyWithValue("endpoint", "/log-level"))
+		Expect(entries[0].Data).To(HaveKeyWithValue("status", BeNumerically("==", http.StatusOK)))
+		Expect(entries[0].Data).To(HaveKey("duration"))
+		Expect(entries[0].Data["params"]).To(Equal(map[string]interface{}{
+			"old_level": "info",
+			"new_level": "debug",
+			"ttl":       "1h0m0s",
+		}))
+	})
+
+	It("records query parameters such as profile seconds", func() {
+		serve(http.MethodGet, "/debug/pprof/heap?seconds=1&debug=1", "")
+
+		entries := records()
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Data["params"]).To(Equal(map[string]interface{}{
+			"seconds": "1",
+			"debug":   "1",
+		}))
+	})
+
+	It("records rate changes", func() {
+		serve(http.MethodPost, "/mutex-profile-fraction", "0")
+
+		entries := records()
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Data["params"]).To(HaveKeyWithValue("fraction", BeNumerically("==", 0)))
+	})
+
+	It("records failed requests with their status", func() {
+		serve(http.MethodPost, "/block-profile-rate", "fast")
+
+		entries := records()
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Data).To(HaveKeyWithValue("status", BeNumerically("==", http.StatusBadRequest)))
+	})
+
+	Context("with token auth", func() {
+		BeforeEach(func() {
+			tokenFile := filepath.Join(GinkgoT().TempDir(), "token")
+			Expect(os.WriteFile(tokenFile, []byte("s3cret"), 0600)).To(Succeed())
+
+			logger := lager.NewLogger("test")
+			logger.RegisterSink(lager.NewWriterSink(logs, lager.DEBUG))
+			handler = cf_debug_server.Handler(sink,
+				cf_debug_server.WithLogger(logger),
+				cf_debug_server.WithTokenAuth(tokenFile, cf_debug_server.AuthControlEndpoints),
+			)
+		})
+
+		It("records refused requests", func() {
+			serve(http.MethodPost, "/log-level", "debug")
+
+			entries := records()
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0].Data).To(HaveKeyWithValue("status", BeNumerically("==", http.StatusUnauthorized)))
+			Expect(sink.GetMinLevel()).To(Equal(lager.INFO))
+		})
+	})
+
+	Context("behind a trusted proxy", func() {
+		BeforeEach(func() {
+			logger := lager.NewLogger("test")
+			logger.RegisterSink(lager.NewWriterSink(logs, lager.DEBUG))
+			handler = cf_debug_server.Handler(sink,
+				cf_debug_server.WithLogger(logger),
+				cf_debug_server.WithTrustedProxies("10.0.0.1"),
+			)
+		})
+
+		It("records the client address from X-Forwarded-For of served requests", func() {
+			req := httptest.NewRequest(http.MethodGet, "/log-level", nil)
+			req.RemoteAddr = "10.0.0.1:5555"
+			req.Header.Set("X-Forwarded-For", "203.0.113.9")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			Expect(rec.Code).To(Equal(http.StatusOK))
+
+			entries := records()
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0].Data).To(HaveKeyWithValue("remote_addr", "10.0.0.1:5555"))
+			Expect(entries[0].Data["params"]).To(HaveKeyWithValue("client_ip", "203.0.113.9"))
+		})
+	})
+})
@@ -0,0 +1,111 @@
+package debugserver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuthScope selects which endpoints require a bearer token.
+type AuthScope int
+
+const (
+	// AuthControlEndpoints protects only the endpoints that change process behavior,
+	// such as /log-level, and leaves the read-only pprof endpoints open.
+	AuthControlEndpoints AuthScope = iota
+	// AuthAllEndpoints protects every endpoint.
+	AuthAllEndpoints
+)
+
+const authRealm = `Bearer realm="debugserver"`
+
+// WithTokenAuth requires requests to carry an "Authorization: Bearer <token>" header
+// matching the content of tokenFile. The file is re-read whenever it changes, so the
+// token can be rotated without a restart. Requests are refused while the file cannot be read.
+func WithTokenAuth(tokenFile string, scope AuthScope) Option {
+	return func(o *options) {
+		o.auth = &tokenAuth{file: tokenFile, scope: scope}
+	}
+}
+
+// tokenAuth checks bearer tokens against a shared secret kept in a file.
+type tokenAuth struct {
+	file  string
+	scope AuthScope
+
+	mu      sync.Mutex
+	modTime time.Time
+	digest  []byte
+}
+
+// wrap refuses requests without the token. Refusals are written like the errors of the
+// control endpoints, as JSON for requests in JSON mode.
+func (a *tokenAuth) wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Check the header first, so that requests without a token are refused the
+		// same way whether or not the token file can be read.
+		token, ok := bearerToken(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", authRealm)
+			writeControlError(w, r, unauthorized("missing bearer token"))
+			return
+		}
+		expected, err := a.tokenDigest()
+		if err != nil {
+			writeControlError(w, r, internalError("debug server auth token is unavailable"))
+			return
+		}
+		actual := sha256.Sum256([]byte(token))
+		if subtle.ConstantTimeCompare(actual[:], expected) != 1 {
+			// RFC 6750 section 3.1: invalid_token is answered with 401, like a missing one.
+			w.Header().Set("WWW-Authenticate", authRealm+`, error="invalid_token"`)
+			writeControlError(w, r, unauthorized("invalid bearer token"))
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// tokenDigest returns the SHA-256 of the current token, reloading the file if it changed.
+// Comparing digests keeps the comparison constant time regardless of token length.
+func (a *tokenAuth) tokenDigest() ([]byte, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	info, err := os.Stat(a.file)
+	if err != nil {
+		return nil, err
+	}
+	if a.digest != nil && info.ModTime().Equal(a.modTime) {
+		return a.digest, nil
+	}
+
+	contents, err := os.ReadFile(a.file)
+	if err != nil {
+		return nil, err
+	}
+	token := bytes.TrimSpace(contents)
+	if len(token) == 0 {
+		return nil, errors.New("debug server auth token file is empty")
+	}
+	digest := sha256.Sum256(token)
+	a.digest = digest[:]
+	a.modTime = info.ModTime()
+	return a.digest, nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
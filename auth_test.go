@@ -0,0 +1,130 @@
+package debugserver_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	cf_debug_server "code.cloudfoundry.org/debugserver"
+	lager "code.cloudfoundry.org/lager/v3"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Token auth", func() {
+	var (
+		sink      *lager.ReconfigurableSink
+		tokenFile string
+		scope     cf_debug_server.AuthScope
+		handler   http.Handler
+	)
+
+	BeforeEach(func() {
+		sink = lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.INFO)
+		tokenFile = filepath.Join(GinkgoT().TempDir(), "token")
+		Expect(os.WriteFile(tokenFile, []byte("s3cret\n"), 0600)).To(Succeed())
+		scope = cf_debug_server.AuthControlEndpoints
+	})
+
+	JustBeforeEach(func() {
+		handler = cf_debug_server.Handler(sink, cf_debug_server.WithTokenAuth(tokenFile, scope))
+	})
+
+	serve := func(method, path, token string) *httptest.ResponseRecorder {
+		if token == "" {
+			return serveRequest(handler, method, path, "debug")
+		}
+		return serveRequest(handler, method, path, "debug", "Authorization", "Bearer "+token)
+	}
+
+	DescribeTable("protects control endpoints",
+		func(path string) {
+			rec := serve(http.MethodPost, path, "")
+			Expect(rec.Code).To(Equal(http.StatusUnauthorized))
+			Expect(rec.Header().Get("WWW-Authenticate")).To(Equal(`Bearer realm="debugserver"`))
+
+			rec = serve(http.MethodPost, path, "wrong")
+			Expect(rec.Code).To(Equal(http.StatusUnauthorized))
+			Expect(rec.Header().Get("WWW-Authenticate")).To(ContainSubstring(`error="invalid_token"`))
+		},
+		Entry("log level", "/log-level"),
+		Entry("block profile rate", "/block-profile-rate"),
+		Entry("mutex profile fraction", "/mutex-profile-fraction"),
+	)
+
+	It("protects the goroutine snapshots, but not the list of them", func() {
+		Expect(serve(http.MethodPost, "/debug/goroutines/snapshots/before", "").Code).To(Equal(http.StatusUnauthorized))
+		Expect(serve(http.MethodDelete, "/debug/goroutines/snapshots/before", "").Code).To(Equal(http.StatusUnauthorized))
+		Expect(serve(http.MethodGet, "/debug/goroutines/snapshots/before", "").Code).To(Equal(http.StatusUnauthorized))
+
+		Expect(serve(http.MethodPost, "/debug/goroutines/snapshots/before", "s3cret").Code).To(Equal(http.StatusOK))
+		Expect(serve(http.MethodGet, "/debug/goroutines/snapshots/before", "s3cret").Code).To(Equal(http.StatusOK))
+		Expect(serve(http.MethodGet, "/debug/goroutines/snapshots", "").Code).To(Equal(http.StatusOK))
+		Expect(serve(http.MethodDelete, "/debug/goroutines/snapshots/before", "s3cret").Code).To(Equal(http.StatusNoContent))
+	})
+
+	It("allows requests with the token", func() {
+		rec := serve(http.MethodPost, "/log-level", "s3cret")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(sink.GetMinLevel()).To(Equal(lager.DEBUG))
+	})
+
+	It("leaves the pprof endpoints open", func() {
+		rec := serve(http.MethodGet, "/debug/pprof/cmdline", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+	})
+
+	It("picks up a rotated token", func() {
+		Expect(os.WriteFile(tokenFile, []byte("rotated"), 0600)).To(Succeed())
+		future := time.Now().Add(time.Minute)
+		Expect(os.Chtimes(tokenFile, future, future)).To(Succeed())
+
+		Expect(serve(http.MethodPost, "/log-level", "s3cret").Code).To(Equal(http.StatusUnauthorized))
+		Expect(serve(http.MethodPost, "/log-level", "rotated").Code).To(Equal(http.StatusOK))
+	})
+
+	It("refuses requests while the token file is missing", func() {
+		Expect(os.Remove(tokenFile)).To(Succeed())
+
+		Expect(serve(http.MethodPost, "/log-level", "s3cret").Code).To(Equal(http.StatusInternalServerError))
+		Expect(sink.GetMinLevel()).To(Equal(lager.INFO))
+	})
+
+	It("refuses requests without a token as unauthorized while the token file is missing", func() {
+		Expect(os.Remove(tokenFile)).To(Succeed())
+
+		rec := serve(http.MethodPost, "/log-level", "")
+		Expect(rec.Code).To(Equal(http.StatusUnauthorized))
+		Expect(rec.Header().Get("WWW-Authenticate")).To(Equal(`Bearer realm="debugserver"`))
+	})
+
+	Context("when reads are protected too", func() {
+		BeforeEach(func() {
+			scope = cf_debug_server.AuthAllEndpoints
+		})
+
+		It("protects the pprof endpoints", func() {
+			Expect(serve(http.MethodGet, "/debug/pprof/cmdline", "").Code).To(Equal(http.StatusUnauthorized))
+			Expect(serve(http.MethodGet, "/debug/pprof/cmdline", "s3cret").Code).To(Equal(http.StatusOK))
+		})
+	})
+
+	Describe("DebugServerConfig.HandlerOptions", func() {
+		It("configures token auth from the config", func() {
+			handler = cf_debug_server.Handler(sink, cf_debug_server.DebugServerConfig{
+				DebugAuthTokenFile:        tokenFile,
+				DebugAuthRequiredForReads: true,
+			}.HandlerOptions()...)
+
+			Expect(serve(http.MethodGet, "/debug/pprof/cmdline", "").Code).To(Equal(http.StatusUnauthorized))
+		})
+
+		It("returns no options when auth is not configured", func() {
+			Expect(cf_debug_server.DebugServerConfig{}.HandlerOptions()).To(BeEmpty())
+		})
+	})
+})
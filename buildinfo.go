@@ -0,0 +1,196 @@
+package debugserver
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"text/tabwriter"
+)
+
+// versionField is an extra field reported by /debug/buildinfo, such as a release name.
+type versionField struct {
+	name  string
+	value func() string
+}
+
+// WithVersionField adds a field with a fixed value, for example the name of the
+// release the process is part of, to the /debug/buildinfo response.
+func WithVersionField(name, value string) Option {
+	return WithVersionFieldFunc(name, func() string { return value })
+}
+
+// WithVersionFieldFunc adds a field to the /debug/buildinfo response whose value is
+// computed on every request.
+func WithVersionFieldFunc(name string, value func() string) Option {
+	return func(o *options) {
+		if value != nil {
+			o.versionFields = append(o.versionFields, versionField{name: name, value: value})
+		}
+	}
+}
+
+type buildInfoModule struct {
+	Path    string           `json:"path"`
+	Version string           `json:"version"`
+	Sum     string           `json:"sum,omitempty"`
+	Replace *buildInfoModule `json:"replace,omitempty"`
+}
+
+type buildInfoVCS struct {
+	System   string `json:"system"`
+	Revision string `json:"revision,omitempty"`
+	Time     string `json:"time,omitempty"`
+	Modified bool   `json:"modified"`
+}
+
+// buildInfo is what the binary knows about how it was built, see debug.ReadBuildInfo.
+type buildInfo struct {
+	Path      string             `json:"path,omitempty"`
+	Main      *buildInfoModule   `json:"main,omitempty"`
+	GoVersion string             `json:"go_version"`
+	VCS       *buildInfoVCS      `json:"vcs,omitempty"`
+	Fields    map[string]string  `json:"fields,omitempty"`
+	Settings  map[string]string  `json:"settings,omitempty"`
+	Deps      []*buildInfoModule `json:"deps,omitempty"`
+
+	// fields and settings keep the order for the text output.
+	fields   []string
+	settings []debug.BuildSetting
+}
+
+// buildInfoHandler serves the build information of the binary together with the
+// registered version fields, as text or, with ?format=json or Accept: application/json, as JSON.
+func buildInfoHandler(fields []versionField) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		asJSON, err := wantsJSONFormat(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		info := readBuildInfo(fields)
+		if asJSON {
+			writeJSON(w, http.StatusOK, info)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		// #nosec G104 - ignore errors writing http response to avoid spamming logs during DoS
+		info.writeText(w)
+	}
+}
+
+// readBuildInfo reads the build information embedded in the binary. Binaries built
+// without module support only report the Go version and the version fields.
+func readBuildInfo(fields []versionField) *buildInfo {
+	info := &buildInfo{GoVersion: runtime.Version()}
+	for _, field := range fields {
+		if info.Fields == nil {
+			info.Fields = map[string]string{}
+		}
+		if _, ok := info.Fields[field.name]; !ok {
+			info.fields = append(info.fields, field.name)
+		}
+		info.Fields[field.name] = field.value()
+	}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info.Path = bi.Path
+	info.Main = newBuildInfoModule(&bi.Main)
+	if bi.GoVersion != "" {
+		info.GoVersion = bi.GoVersion
+	}
+	info.settings = bi.Settings
+	for _, setting := range bi.Settings {
+		switch setting.Key {
+		case "vcs":
+			info.vcs().System = setting.Value
+		case "vcs.revision":
+			info.vcs().Revision = setting.Value
+		case "vcs.time":
+			info.vcs().Time = setting.Value
+		case "vcs.modified":
+			info.vcs().Modified = setting.Value == "true"
+		}
+		if info.Settings == nil {
+			info.Settings = map[string]string{}
+		}
+		info.Settings[setting.Key] = setting.Value
+	}
+	for _, dep := range bi.Deps {
+		info.Deps = append(info.Deps, newBuildInfoModule(dep))
+	}
+	return info
+}
+
+func (b *buildInfo) vcs() *buildInfoVCS {
+	if b.VCS == nil {
+		b.VCS = &buildInfoVCS{}
+	}
+	return b.VCS
+}
+
+func newBuildInfoModule(m *debug.Module) *buildInfoModule {
+	if m == nil {
+		return nil
+	}
+	return &buildInfoModule{
+		Path:    m.Path,
+		Version: m.Version,
+		Sum:     m.Sum,
+		Replace: newBuildInfoModule(m.Replace),
+	}
+}
+
+// writeText writes the build information in aligned columns, with the settings in
+// the order the toolchain reports them and the dependencies last.
+func (b *buildInfo) writeText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if b.Path != "" {
+		fmt.Fprintf(tw, "path\t%s\n", b.Path)
+	}
+	if b.Main != nil && b.Main.Version != "" {
+		fmt.Fprintf(tw, "version\t%s\n", b.Main.Version)
+	}
+	fmt.Fprintf(tw, "go\t%s\n", b.GoVersion)
+	if b.VCS != nil {
+		revision := b.VCS.Revision
+		if b.VCS.Modified {
+			revision += " (modified)"
+		}
+		fmt.Fprintf(tw, "%s revision\t%s\n", b.VCS.System, revision)
+		if b.VCS.Time != "" {
+			fmt.Fprintf(tw, "%s time\t%s\n", b.VCS.System, b.VCS.Time)
+		}
+	}
+	for _, name := range b.fields {
+		fmt.Fprintf(tw, "%s\t%s\n", name, b.Fields[name])
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(b.settings) > 0 {
+		fmt.Fprintf(w, "\nbuild settings:\n")
+		for _, setting := range b.settings {
+			fmt.Fprintf(w, "  %s=%s\n", setting.Key, setting.Value)
+		}
+	}
+	if len(b.Deps) > 0 {
+		fmt.Fprintf(w, "\ndependencies:\n")
+		tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, dep := range b.Deps {
+			line := "  " + dep.Path + "\t" + dep.Version
+			if dep.Replace != nil {
+				line += "\t=> " + dep.Replace.Path + " " + dep.Replace.Version
+			}
+			fmt.Fprintln(tw, line)
+		}
+		return tw.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package debugserver_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"runtime"
+
+	cf_debug_server "code.cloudfoundry.org/debugserver"
+	lager "code.cloudfoundry.org/lager/v3"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("/debug/buildinfo", func() {
+	var (
+		handler http.Handler
+		calls   int
+	)
+
+	BeforeEach(func() {
+		calls = 0
+		sink := lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.INFO)
+		handler = cf_debug_server.Handler(sink,
+			cf_debug_server.WithVersionField("release", "routing/0.300.0"),
+			cf_debug_server.WithVersionFieldFunc("calls", func() string {
+				calls++
+				return "dynamic"
+			}),
+		)
+	})
+
+	It("returns the build information as JSON", func() {
+		rec := serveRequest(handler, http.MethodGet, "/debug/buildinfo", "", "Accept", "application/json")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Header().Get("Content-Type")).To(Equal("application/json"))
+
+		var info struct {
+			Path      string            `json:"path"`
+			GoVersion string            `json:"go_version"`
+			Fields    map[string]string `json:"fields"`
+			Settings  map[string]string `json:"settings"`
+			Deps      []struct {
+				Path    string `json:"path"`
+				Version string `json:"version"`
+			} `json:"deps"`
+		}
+		Expect(json.Unmarshal(rec.Body.Bytes(), &info)).To(Succeed())
+		Expect(info.Path).To(HavePrefix("code.cloudfoundry.org/debugserver"))
+		Expect(info.GoVersion).To(Equal(runtime.Version()))
+		Expect(info.Fields).To(Equal(map[string]string{"release": "routing/0.300.0", "calls": "dynamic"}))
+		Expect(info.Settings).To(HaveKey("GOOS"))
+		Expect(info.Deps).To(ContainElement(HaveField("Path", "code.cloudfoundry.org/lager/v3")))
+		Expect(calls).To(Equal(1))
+	})
+
+	It("returns the build information as text by default", func() {
+		rec := serveRequest(handler, http.MethodGet, "/debug/buildinfo", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Header().Get("Content-Type")).To(Equal("text/plain; charset=utf-8"))
+
+		body := rec.Body.String()
+		Expect(body).To(MatchRegexp(`(?m)^path +code\.cloudfoundry\.org/debugserver`))
+		Expect(body).To(MatchRegexp(`(?m)^go +` + runtime.Version() + `$`))
+		Expect(body).To(MatchRegexp(`(?m)^release +routing/0\.300\.0$`))
+		Expect(body).To(ContainSubstring("\nbuild settings:\n  "))
+		Expect(body).To(MatchRegexp(`(?m)^dependencies:\n  code\.cloudfoundry\.org/lager/v3 +v3\.`))
+	})
+
+	It("calls the field functions on every request", func() {
+		serveRequest(handler, http.MethodGet, "/debug/buildinfo?format=text", "")
+		serveRequest(handler, http.MethodGet, "/debug/buildinfo?format=json", "")
+		Expect(calls).To(Equal(2))
+	})
+
+	It("rejects unknown formats", func() {
+		Expect(serveRequest(handler, http.MethodGet, "/debug/buildinfo?format=yaml", "").Code).To(Equal(http.StatusBadRequest))
+	})
+})
@@ -0,0 +1,271 @@
+package debugserver
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// bundleEntry describes one file of a diagnostic bundle in its manifest.
+type bundleEntry struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CapturedAt  time.Time `json:"captured_at"`
+	Size        int       `json:"size"`
+	Error       string    `json:"error,omitempty"`
+
+	data []byte
+}
+
+type bundleManifest struct {
+	CreatedAt time.Time      `json:"created_at"`
+	Duration  string         `json:"duration"`
+	Files     []*bundleEntry `json:"files"`
+}
+
+// bundleHandler serves a single archive with everything usually collected during an incident.
+// Query parameters:
+//
+//	format=tar.gz|zip  archive format, tar.gz by default
+//	seconds=N          include an N second CPU profile
+//	trace=N            include an N second execution trace
+//
+// The CPU profile and execution trace take their slots from the captures shared with
+// the pprof endpoints and the watchdog, so a bundle asking for one while it is taken is
+// queued or refused like /debug/pprof/profile.
+func bundleHandler(o *options, targets []*logLevelTarget) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := r.FormValue("format")
+		if format == "" {
+			format = "tar.gz"
+		}
+		if format != "tar.gz" && format != "zip" {
+			http.Error(w, "invalid format "+strconv.Quote(format)+", use tar.gz or zip", http.StatusBadRequest)
+			return
+		}
+		cpuSeconds, err := queryDuration(r.FormValue("seconds"))
+		if err != nil {
+			http.Error(w, "invalid seconds: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		traceSeconds, err := queryDuration(r.FormValue("trace"))
+		if err != nil {
+			http.Error(w, "invalid trace: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var kinds []profileKind
+		if cpuSeconds > 0 {
+			kinds = append(kinds, cpuProfileKind)
+		}
+		if traceSeconds > 0 {
+			kinds = append(kinds, executionTraceKind)
+		}
+		if len(kinds) > 0 {
+			capture, ok := o.acquireCapture(w, r, kinds, max(cpuSeconds, traceSeconds))
+			if !ok {
+				return
+			}
+			defer captures.release(capture)
+		}
+
+		start := time.Now()
+		entries := collectBundle(r.Context(), targets, o.versionFields, cpuSeconds, traceSeconds)
+		if r.Context().Err() != nil {
+			return
+		}
+
+		manifest := &bundleEntry{Name: "manifest.json", Description: "what was captured and when", CapturedAt: time.Now()}
+		manifest.data, err = json.MarshalIndent(bundleManifest{
+			CreatedAt: start.UTC(),
+			Duration:  time.Since(start).String(),
+			Files:     entries,
+		}, "", "  ")
+		if err != nil {
+			http.Error(w, "failed to write manifest: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		manifest.Size = len(manifest.data)
+
+		filename := "debug-bundle-" + start.UTC().Format("20060102T150405Z") + "." + format
+		var archive bytes.Buffer
+		if format == "zip" {
+			err = writeZipBundle(&archive, append([]*bundleEntry{manifest}, entries...))
+			w.Header().Set("Content-Type", "application/zip")
+		} else {
+			err = writeTarGzBundle(&archive, append([]*bundleEntry{manifest}, entries...))
+			w.Header().Set("Content-Type", "application/gzip")
+		}
+		if err != nil {
+			http.Error(w, "failed to write bundle: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+		// #nosec G104 - ignore errors writing http response to avoid spamming logs during DoS
+		archive.WriteTo(w)
+	}
+}
+
+// collectBundle captures the snapshot profiles and, if requested, the timed
+// CPU profile and execution trace, which run concurrently. The caller holds the
+// capture slots of the timed ones.
+func collectBundle(ctx context.Context, targets []*logLevelTarget, fields []versionField, cpuDuration, traceDuration time.Duration) []*bundleEntry {
+	var entries []*bundleEntry
+	capture := func(name, description string, fn func(io.Writer) error) *bundleEntry {
+		entry := &bundleEntry{Name: name, Description: description}
+		var buf bytes.Buffer
+		if err := fn(&buf); err != nil {
+			entry.Error = err.Error()
+		} else {
+			entry.data = buf.Bytes()
+			entry.Size = len(entry.data)
+		}
+		entry.CapturedAt = time.Now().UTC()
+		return entry
+	}
+
+	entries = append(entries, capture("goroutine.txt", "goroutine stack dump (debug=2)", func(w io.Writer) error {
+		return pprof.Lookup("goroutine").WriteTo(w, 2)
+	}))
+	for _, name := range []string{"heap", "allocs", "block", "mutex", "threadcreate"} {
+		profile := name
+		entries = append(entries, capture(profile+".pb.gz", profile+" profile", func(w io.Writer) error {
+			return pprof.Lookup(profile).WriteTo(w, 0)
+		}))
+	}
+	entries = append(entries, capture("buildinfo.txt", "build information", func(w io.Writer) error {
+		return readBuildInfo(fields).writeText(w)
+	}))
+	entries = append(entries, capture("metrics.json", "runtime/metrics samples", func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(struct {
+			Metrics []runtimeMetric `json:"metrics"`
+		}{readRuntimeMetrics()})
+	}))
+	if len(targets) > 0 {
+		entries = append(entries, capture("log-levels.txt", "levels of all log controllers, as listed by /log-levels", func(w io.Writer) error {
+			return writeLogLevelsText(w, logLevelStatuses(targets, nil))
+		}))
+	}
+
+	var wg sync.WaitGroup
+	var cpu, tr *bundleEntry
+	if cpuDuration > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cpu = capture("cpu.pb.gz", "CPU profile over "+cpuDuration.String(), func(w io.Writer) error {
+				if err := pprof.StartCPUProfile(w); err != nil {
+					return err
+				}
+				defer pprof.StopCPUProfile()
+				return sleepContext(ctx, cpuDuration)
+			})
+		}()
+	}
+	if traceDuration > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tr = capture("trace.out", "execution trace over "+traceDuration.String(), func(w io.Writer) error {
+				if err := trace.Start(w); err != nil {
+					return err
+				}
+				defer trace.Stop()
+				return sleepContext(ctx, traceDuration)
+			})
+		}()
+	}
+	wg.Wait()
+	for _, entry := range []*bundleEntry{cpu, tr} {
+		if entry != nil {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+func writeTarGzBundle(w io.Writer, entries []*bundleEntry) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, entry := range entries {
+		if entry.Error != "" {
+			continue
+		}
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    entry.Name,
+			Mode:    0644,
+			Size:    int64(len(entry.data)),
+			ModTime: entry.CapturedAt,
+		}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(entry.data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeZipBundle(w io.Writer, entries []*bundleEntry) error {
+	zw := zip.NewWriter(w)
+	for _, entry := range entries {
+		if entry.Error != "" {
+			continue
+		}
+		f, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     entry.Name,
+			Method:   zip.Deflate,
+			Modified: entry.CapturedAt,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(entry.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// queryDuration parses a number of seconds from a query parameter; empty means zero.
+func queryDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if seconds < 0 {
+		return 0, errors.New("must not be negative")
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// sleepContext waits for d, or returns the context error if it is done first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,118 @@
+package debugserver_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	cf_debug_server "code.cloudfoundry.org/debugserver"
+	lager "code.cloudfoundry.org/lager/v3"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("/debug/bundle", func() {
+	var (
+		sink    *lager.ReconfigurableSink
+		handler http.Handler
+	)
+
+	BeforeEach(func() {
+		sink = lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.ERROR)
+		handler = cf_debug_server.Handler(sink)
+	})
+
+	readTarGz := func(data []byte) map[string][]byte {
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		Expect(err).NotTo(HaveOccurred())
+		tr := tar.NewReader(gz)
+		files := map[string][]byte{}
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			Expect(err).NotTo(HaveOccurred())
+			files[header.Name], err = io.ReadAll(tr)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		return files
+	}
+
+	type manifest struct {
+		Files []struct {
+			Name  string `json:"name"`
+			Size  int    `json:"size"`
+			Error string `json:"error"`
+		} `json:"files"`
+	}
+
+	It("returns a tar.gz bundle with the snapshot profiles and a manifest", func() {
+		rec := serveRequest(handler, http.MethodGet, "/debug/bundle", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Header().Get("Content-Type")).To(Equal("application/gzip"))
+		Expect(rec.Header().Get("Content-Disposition")).To(MatchRegexp(`attachment; filename="debug-bundle-\d{8}T\d{6}Z\.tar\.gz"`))
+
+		files := readTarGz(rec.Body.Bytes())
+		Expect(files).To(HaveKey("manifest.json"))
+		for _, name := range []string{"goroutine.txt", "heap.pb.gz", "allocs.pb.gz", "block.pb.gz", "mutex.pb.gz", "threadcreate.pb.gz", "buildinfo.txt", "metrics.json"} {
+			Expect(files).To(HaveKey(name))
+		}
+		Expect(string(files["goroutine.txt"])).To(ContainSubstring("goroutine "))
+		Expect(string(files["log-levels.txt"])).To(Equal("/log-level  error\n"))
+		Expect(files).NotTo(HaveKey("cpu.pb.gz"))
+		Expect(files).NotTo(HaveKey("trace.out"))
+
+		var m manifest
+		Expect(json.Unmarshal(files["manifest.json"], &m)).To(Succeed())
+		for _, f := range m.Files {
+			Expect(f.Error).To(BeEmpty(), f.Name)
+			Expect(f.Size).To(Equal(len(files[f.Name])), f.Name)
+		}
+	})
+
+	It("includes the levels of all log controllers", func() {
+		accessSink := lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.INFO)
+		handler = cf_debug_server.Handler(sink, cf_debug_server.WithNamedLogController("access", accessSink))
+
+		files := readTarGz(serveRequest(handler, http.MethodGet, "/debug/bundle", "").Body.Bytes())
+		Expect(string(files["log-levels.txt"])).To(MatchRegexp(`^/log-level\s+error\n/log-level/access\s+info\n$`))
+	})
+
+	It("includes a CPU profile and execution trace when requested", func() {
+		rec := serveRequest(handler, http.MethodGet, "/debug/bundle?seconds=1&trace=1", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+
+		files := readTarGz(rec.Body.Bytes())
+		Expect(files["cpu.pb.gz"]).NotTo(BeEmpty())
+		Expect(string(files["trace.out"])).To(HavePrefix("go 1."))
+	})
+
+	It("returns a zip bundle when requested", func() {
+		rec := serveRequest(handler, http.MethodGet, "/debug/bundle?format=zip", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Header().Get("Content-Type")).To(Equal("application/zip"))
+
+		zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+		Expect(err).NotTo(HaveOccurred())
+		var names []string
+		for _, f := range zr.File {
+			names = append(names, f.Name)
+		}
+		Expect(names).To(ContainElements("manifest.json", "goroutine.txt", "heap.pb.gz"))
+	})
+
+	DescribeTable("rejects invalid parameters",
+		func(target string) {
+			Expect(serveRequest(handler, http.MethodGet, target, "").Code).To(Equal(http.StatusBadRequest))
+		},
+		Entry("format", "/debug/bundle?format=rar"),
+		Entry("seconds", "/debug/bundle?seconds=soon"),
+		Entry("negative trace", "/debug/bundle?trace=-1"),
+	)
+})
@@ -2,6 +2,9 @@ package debugserver_test
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -21,3 +24,15 @@ var _ = SynchronizedBeforeSuite(func() []byte {
 }, func(encodedBuiltArtifacts []byte) {
 	address = fmt.Sprintf("127.0.0.1:%d", 10000+GinkgoParallelProcess())
 })
+
+// serveRequest sends a request with body to handler and records the response. headers
+// are pairs of a name and a value, such as "Accept", "application/json".
+func serveRequest(handler http.Handler, method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
@@ -201,24 +201,14 @@ var _ = Describe("CF Debug Server", func() {
 			})
 		})
 
-		Context("invalid TLS scheme", func() {
-			It("returns error if TLS is used", func() {
+		Context("when the request is made over TLS", func() {
+			It("accepts the log level", func() {
 				req.TLS = &tls.ConnectionState{}
 				actual, err := cf_debug_server.ValidateAndNormalize(writer, req, []byte("debug"))
-				Expect(err).To(MatchError(ContainSubstring("invalid scheme")))
-				Expect(actual).To(BeEmpty())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(actual).To(Equal("debug"))
 			})
 		})
-
-		It("returns error if the request is made over HTTPS", func() {
-			// Simulate HTTPS by assigning a non-nil TLS connection state
-			req.TLS = &tls.ConnectionState{}
-			actual, err := cf_debug_server.ValidateAndNormalize(writer, req, []byte("debug"))
-
-			Expect(err).To(MatchError(ContainSubstring("invalid scheme")))
-			Expect(actual).To(BeEmpty())
-		})
-
 	})
 
 })
@@ -0,0 +1,233 @@
+package debugserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	yaml "go.yaml.in/yaml/v3"
+)
+
+const (
+	defaultReadHeaderTimeout  = 10 * time.Second
+	defaultIdleTimeout        = 2 * time.Minute
+	defaultMaxProfileDuration = 5 * time.Minute
+)
+
+// LoadDebugServerConfig reads the config from a JSON file or, for .yml and .yaml files,
+// a YAML file using the same keys. Keys that are not debug server settings are ignored,
+// so the debug settings can be read from the config file of the whole component.
+func LoadDebugServerConfig(path string) (DebugServerConfig, error) {
+	var cfg DebugServerConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		data, err = yamlToJSON(data)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid debug server config %s: %w", path, err)
+		}
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid debug server config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// yamlToJSON converts a YAML document to JSON, so that YAML configs are decoded with
+// the json tags and Duration parsing of the config.
+func yamlToJSON(data []byte) ([]byte, error) {
+	var value interface{}
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(value)
+}
+
+// WithDefaults returns the config with defaults for the settings that are not set:
+// a read header timeout of 10s, an idle timeout of 2m, a shutdown timeout of 1m and
+// profiles and traces of at most 5m.
+func (c DebugServerConfig) WithDefaults() DebugServerConfig {
+	setDefault := func(d *Duration, def time.Duration) {
+		if *d == 0 {
+			*d = Duration(def)
+		}
+	}
+	setDefault(&c.DebugReadHeaderTimeout, defaultReadHeaderTimeout)
+	setDefault(&c.DebugIdleTimeout, defaultIdleTimeout)
+	setDefault(&c.DebugShutdownTimeout, defaultShutdownTimeout)
+	setDefault(&c.DebugMaxProfileDuration, defaultMaxProfileDuration)
+	return c
+}
+
+// Timeouts returns the connection timeouts from the config.
+func (c DebugServerConfig) Timeouts() Timeouts {
+	return Timeouts{
+		ReadHeader: time.Duration(c.DebugReadHeaderTimeout),
+		Read:       time.Duration(c.DebugReadTimeout),
+		Write:      time.Duration(c.DebugWriteTimeout),
+		Idle:       time.Duration(c.DebugIdleTimeout),
+		Shutdown:   time.Duration(c.DebugShutdownTimeout),
+	}
+}
+
+// Validate checks the config without touching the files it refers to, and returns an
+// error naming every invalid setting by its JSON key.
+func (c DebugServerConfig) Validate() error {
+	var errs []error
+	invalid := func(key, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(key+": "+format, args...))
+	}
+
+	unixSocket := IsUnixSocketAddress(c.DebugAddress)
+	switch {
+	case c.DebugAddress == "":
+		invalid("debug_address", "is required")
+	case unixSocket:
+		if path := strings.TrimPrefix(c.DebugAddress, UnixSocketScheme); !filepath.IsAbs(path) {
+			invalid("debug_address", "%q must be unix:// followed by an absolute path", c.DebugAddress)
+		}
+	default:
+		if err := validateHostPort(c.DebugAddress); err != nil {
+			invalid("debug_address", "%q must be host:port or unix:///path/to/socket: %s", c.DebugAddress, err)
+		}
+	}
+
+	if c.DebugCertFile != "" || c.DebugKeyFile != "" || c.DebugCACertFile != "" {
+		tlsFiles := []struct{ key, value string }{
+			{"debug_cert_file", c.DebugCertFile},
+			{"debug_key_file", c.DebugKeyFile},
+			{"debug_ca_cert_file", c.DebugCACertFile},
+		}
+		for _, file := range tlsFiles {
+			if file.value == "" {
+				invalid(file.key, "is required when any of the TLS files is set")
+			}
+		}
+	}
+
+	if _, err := parseSocketMode(c.DebugSocketMode); err != nil {
+		invalid("debug_socket_mode", "%s", err)
+	}
+	if !unixSocket {
+		socketSettings := []struct{ key, value string }{
+			{"debug_socket_mode", c.DebugSocketMode},
+			{"debug_socket_owner", c.DebugSocketOwner},
+			{"debug_socket_group", c.DebugSocketGroup},
+		}
+		for _, setting := range socketSettings {
+			if setting.value != "" {
+				invalid(setting.key, "only applies to unix:// addresses")
+			}
+		}
+	}
+
+	if c.DebugAuthRequiredForReads && c.DebugAuthTokenFile == "" {
+		invalid("debug_auth_required_for_reads", "requires debug_auth_token_file")
+	}
+
+	if _, err := parseNetworks(c.DebugAllowedNetworks); err != nil {
+		invalid("debug_allowed_networks", "%s", err)
+	}
+	if _, err := parseNetworks(c.DebugTrustedProxies); err != nil {
+		invalid("debug_trusted_proxies", "%s", err)
+	}
+	if c.DebugStrictNetworkPolicy && !unixSocket && c.DebugAuthTokenFile == "" && c.DebugCertFile == "" {
+		if host, _, err := net.SplitHostPort(c.DebugAddress); err == nil && !isLoopbackHost(host) {
+			invalid("debug_address", "%q is not a loopback address, which debug_strict_network_policy only allows with debug_auth_token_file or TLS", c.DebugAddress)
+		}
+	}
+
+	// The log controllers behind /log-level/NAME are passed as options, so only the form
+	// of the name is checked here and newServer checks that the controller exists.
+	for _, path := range c.DebugEnabledEndpoints {
+		name, named := strings.CutPrefix(path, "/log-level/")
+		if !isBuiltinEndpoint(&options{}, path) && !(named && logControllerNameRegexp.MatchString(name)) {
+			invalid("debug_enabled_endpoints", "unknown endpoint %q", path)
+		}
+	}
+
+	durations := []struct {
+		key   string
+		value Duration
+	}{
+		{"debug_flight_recorder_window", c.DebugFlightRecorderWindow},
+		{"debug_max_profile_duration", c.DebugMaxProfileDuration},
+		{"debug_profile_queue_timeout", c.DebugProfileQueueTimeout},
+		{"debug_read_header_timeout", c.DebugReadHeaderTimeout},
+		{"debug_read_timeout", c.DebugReadTimeout},
+		{"debug_write_timeout", c.DebugWriteTimeout},
+		{"debug_idle_timeout", c.DebugIdleTimeout},
+		{"debug_shutdown_timeout", c.DebugShutdownTimeout},
+	}
+	for _, d := range durations {
+		if d.value < 0 {
+			invalid(d.key, "must not be negative, got %s", time.Duration(d.value))
+		}
+	}
+	if c.DebugWriteTimeout > 0 && (c.DebugMaxProfileDuration <= 0 || c.DebugWriteTimeout <= c.DebugMaxProfileDuration) {
+		invalid("debug_write_timeout", "must be longer than debug_max_profile_duration, or profiles are cut off")
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateHostPort(address string) error {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return nil
+}
+
+// isLoopbackHost reports whether host is localhost or a loopback IP address.
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// NewFromConfig creates a debug server from the config, with the defaults of WithDefaults
+// for the settings that are not set. It returns the errors of Validate, and of loading
+// the TLS files, instead of failing when run. opts are applied after the config, so they
+// can add endpoints or override settings.
+func NewFromConfig(cfg DebugServerConfig, zapCtrl zapLogLevelController, opts ...Option) (*Server, error) {
+	cfg = cfg.WithDefaults()
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid debug server config: %w", err)
+	}
+	tlsConfig, err := cfg.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	socketOpts, err := cfg.UnixSocketOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	configOpts := append(cfg.HandlerOptions(),
+		WithAddress(cfg.DebugAddress),
+		WithLogController(zapCtrl),
+		WithTLSConfig(tlsConfig),
+		WithUnixSocketOptions(socketOpts),
+		WithTimeouts(cfg.Timeouts()),
+	)
+	return New(append(configOpts, opts...)...)
+}
@@ -0,0 +1,216 @@
+package debugserver
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// configSetting is a DebugServerConfig setting that can be set from a flag or an
+// environment variable.
+type configSetting struct {
+	flag  string
+	key   string
+	usage string
+	bool  bool
+	set   func(c *DebugServerConfig, value string) error
+}
+
+var configSettings = []configSetting{
+	stringSetting(DebugFlag, "debug_address", "host:port or unix:///path/to/socket for serving pprof debugging info",
+		func(c *DebugServerConfig) *string { return &c.DebugAddress }),
+	stringSetting("debugCertFile", "debug_cert_file", "certificate file of the debug server, enables TLS",
+		func(c *DebugServerConfig) *string { return &c.DebugCertFile }),
+	stringSetting("debugKeyFile", "debug_key_file", "private key file of the debug server certificate",
+		func(c *DebugServerConfig) *string { return &c.DebugKeyFile }),
+	stringSetting("debugCACertFile", "debug_ca_cert_file", "CA file that debug server clients must present a certificate of",
+		func(c *DebugServerConfig) *string { return &c.DebugCACertFile }),
+	{
+		flag:  DebugSocketModeFlag,
+		key:   "debug_socket_mode",
+		usage: "octal permissions of the debug server unix socket (default 0600)",
+		set: func(c *DebugServerConfig, value string) error {
+			if _, err := parseSocketMode(value); err != nil {
+				return err
+			}
+			c.DebugSocketMode = value
+			return nil
+		},
+	},
+	stringSetting(DebugSocketOwnerFlag, "debug_socket_owner", "user name or id owning the debug server unix socket",
+		func(c *DebugServerConfig) *string { return &c.DebugSocketOwner }),
+	stringSetting(DebugSocketGroupFlag, "debug_socket_group", "group name or id owning the debug server unix socket",
+		func(c *DebugServerConfig) *string { return &c.DebugSocketGroup }),
+	stringSetting("debugAuthTokenFile", "debug_auth_token_file", "file holding the bearer token required by the debug server",
+		func(c *DebugServerConfig) *string { return &c.DebugAuthTokenFile }),
+	boolSetting("debugAuthRequiredForReads", "debug_auth_required_for_reads", "require the bearer token for the read endpoints too",
+		func(c *DebugServerConfig) *bool { return &c.DebugAuthRequiredForReads }),
+	listSetting("debugAllowedNetworks", "debug_allowed_networks", "comma separated CIDRs or addresses of the clients allowed to use the debug server (default all)",
+		func(c *DebugServerConfig) *[]string { return &c.DebugAllowedNetworks }),
+	listSetting("debugTrustedProxies", "debug_trusted_proxies", "comma separated CIDRs or addresses of proxies whose X-Forwarded-For header is trusted",
+		func(c *DebugServerConfig) *[]string { return &c.DebugTrustedProxies }),
+	boolSetting("debugStrictNetworkPolicy", "debug_strict_network_policy", "refuse to listen on non-loopback addresses without auth or TLS",
+		func(c *DebugServerConfig) *bool { return &c.DebugStrictNetworkPolicy }),
+	boolSetting("debugFlightRecorder", "debug_flight_recorder_enabled", "keep an execution trace of the last few seconds",
+		func(c *DebugServerConfig) *bool { return &c.DebugFlightRecorderEnabled }),
+	durationSetting("debugFlightRecorderWindow", "debug_flight_recorder_window", "how much execution trace the flight recorder keeps",
+		func(c *DebugServerConfig) *Duration { return &c.DebugFlightRecorderWindow }),
+	{
+		flag:  "debugFlightRecorderMaxBytes",
+		key:   "debug_flight_recorder_max_bytes",
+		usage: "upper bound on the size of the flight recorder trace",
+		set: func(c *DebugServerConfig, value string) error {
+			n, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid number of bytes %q", value)
+			}
+			c.DebugFlightRecorderMaxBytes = n
+			return nil
+		},
+	},
+	listSetting("debugEnabledEndpoints", "debug_enabled_endpoints", "comma separated paths of the built-in debug endpoints to serve (default all)",
+		func(c *DebugServerConfig) *[]string { return &c.DebugEnabledEndpoints }),
+	durationSetting("debugMaxProfileDuration", "debug_max_profile_duration", "longest profile or trace the debug server records",
+		func(c *DebugServerConfig) *Duration { return &c.DebugMaxProfileDuration }),
+	durationSetting("debugProfileQueueTimeout", "debug_profile_queue_timeout", "how long a profile request waits for the one in progress",
+		func(c *DebugServerConfig) *Duration { return &c.DebugProfileQueueTimeout }),
+	durationSetting("debugReadHeaderTimeout", "debug_read_header_timeout", "time allowed to read debug request headers",
+		func(c *DebugServerConfig) *Duration { return &c.DebugReadHeaderTimeout }),
+	durationSetting("debugReadTimeout", "debug_read_timeout", "time allowed to read a debug request",
+		func(c *DebugServerConfig) *Duration { return &c.DebugReadTimeout }),
+	durationSetting("debugWriteTimeout", "debug_write_timeout", "time allowed to write a debug response, longer than the longest profile",
+		func(c *DebugServerConfig) *Duration { return &c.DebugWriteTimeout }),
+	durationSetting("debugIdleTimeout", "debug_idle_timeout", "how long idle debug connections are kept open",
+		func(c *DebugServerConfig) *Duration { return &c.DebugIdleTimeout }),
+	durationSetting("debugShutdownTimeout", "debug_shutdown_timeout", "time in-flight debug requests get to finish on shutdown",
+		func(c *DebugServerConfig) *Duration { return &c.DebugShutdownTimeout }),
+}
+
+func stringSetting(name, key, usage string, field func(*DebugServerConfig) *string) configSetting {
+	return configSetting{flag: name, key: key, usage: usage, set: func(c *DebugServerConfig, value string) error {
+		*field(c) = value
+		return nil
+	}}
+}
+
+func listSetting(name, key, usage string, field func(*DebugServerConfig) *[]string) configSetting {
+	return configSetting{flag: name, key: key, usage: usage, set: func(c *DebugServerConfig, value string) error {
+		*field(c) = nil
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*field(c) = append(*field(c), item)
+			}
+		}
+		return nil
+	}}
+}
+
+func boolSetting(name, key, usage string, field func(*DebugServerConfig) *bool) configSetting {
+	return configSetting{flag: name, key: key, usage: usage, bool: true, set: func(c *DebugServerConfig, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		*field(c) = b
+		return nil
+	}}
+}
+
+func durationSetting(name, key, usage string, field func(*DebugServerConfig) *Duration) configSetting {
+	return configSetting{flag: name, key: key, usage: usage, set: func(c *DebugServerConfig, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		*field(c) = Duration(d)
+		return nil
+	}}
+}
+
+// configFlagValue holds the raw value of a setting flag, which is only applied to a
+// config by MergeFlags. Invalid values are rejected while parsing the flags.
+type configFlagValue struct {
+	setting *configSetting
+	value   string
+}
+
+func (v *configFlagValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return v.value
+}
+
+func (v *configFlagValue) Set(value string) error {
+	if err := v.setting.set(&DebugServerConfig{}, value); err != nil {
+		return err
+	}
+	v.value = value
+	return nil
+}
+
+func (v *configFlagValue) IsBoolFlag() bool {
+	return v.setting.bool
+}
+
+// AddConfigFlags registers a flag for every DebugServerConfig setting, named like the
+// flags of AddFlags with prefix in front, for example -debugAddr and -debugWriteTimeout,
+// or -router.debugAddr with the prefix "router.". Use MergeFlags to apply them to a config.
+// With an empty prefix, DebugAddress and DebugUnixSocketOptions read the flags as well.
+func AddConfigFlags(flags *flag.FlagSet, prefix string) {
+	for i := range configSettings {
+		setting := &configSettings[i]
+		flags.Var(&configFlagValue{setting: setting}, prefix+setting.flag, setting.usage)
+	}
+}
+
+// MergeFlags applies the flags registered by AddConfigFlags (or AddFlags) with prefix,
+// and environment variables, over cfg. A flag set on the command line takes precedence
+// over the environment variable of the same setting, which takes precedence over cfg.
+// Settings set nowhere keep the value of cfg, and WithDefaults fills in what is left.
+//
+// The environment variables are named after the JSON keys of the config, upper case,
+// with prefix in front: DEBUG_ADDRESS, or ROUTER_DEBUG_ADDRESS with the prefix "router.".
+// Environment variables that are set but empty are ignored.
+func MergeFlags(cfg DebugServerConfig, flags *flag.FlagSet, prefix string) (DebugServerConfig, error) {
+	set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	for i := range configSettings {
+		setting := &configSettings[i]
+		name := prefix + setting.flag
+		if set[name] {
+			if err := setting.set(&cfg, flags.Lookup(name).Value.String()); err != nil {
+				return cfg, fmt.Errorf("flag -%s: %w", name, err)
+			}
+			continue
+		}
+		env := configEnvName(prefix, setting.key)
+		if value := os.Getenv(env); value != "" {
+			if err := setting.set(&cfg, value); err != nil {
+				return cfg, fmt.Errorf("environment variable %s: %w", env, err)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// configEnvName turns the prefix and JSON key of a setting into an environment variable
+// name, replacing everything but letters and digits in the prefix with underscores.
+func configEnvName(prefix, key string) string {
+	prefix = strings.Map(func(r rune) rune {
+		if ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '_'
+	}, prefix)
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+	return strings.ToUpper(prefix + key)
+}
@@ -0,0 +1,89 @@
+package debugserver_test
+
+import (
+	"flag"
+	"io"
+	"time"
+
+	cf_debug_server "code.cloudfoundry.org/debugserver"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config flags", func() {
+	var flags *flag.FlagSet
+
+	BeforeEach(func() {
+		flags = flag.NewFlagSet("test", flag.ContinueOnError)
+		flags.SetOutput(io.Discard)
+	})
+
+	Describe("AddConfigFlags", func() {
+		It("registers a flag for every setting with the prefix", func() {
+			cf_debug_server.AddConfigFlags(flags, "router.")
+			for _, name := range []string{"debugAddr", "debugCACertFile", "debugSocketMode", "debugAuthTokenFile", "debugEnabledEndpoints", "debugMaxProfileDuration", "debugWriteTimeout"} {
+				Expect(flags.Lookup("router."+name)).NotTo(BeNil(), name)
+			}
+			Expect(flags.Lookup("debugAddr")).To(BeNil())
+		})
+
+		It("works with DebugAddress and DebugUnixSocketOptions without a prefix", func() {
+			cf_debug_server.AddConfigFlags(flags, "")
+			Expect(flags.Parse([]string{"-debugAddr", "unix:///tmp/debug.sock", "-debugSocketMode", "0660"})).To(Succeed())
+			Expect(cf_debug_server.DebugAddress(flags)).To(Equal("unix:///tmp/debug.sock"))
+			opts, err := cf_debug_server.DebugUnixSocketOptions(flags)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts.Mode).To(BeEquivalentTo(0660))
+		})
+
+		It("rejects invalid values while parsing", func() {
+			cf_debug_server.AddConfigFlags(flags, "")
+			Expect(flags.Parse([]string{"-debugWriteTimeout", "soon"})).To(MatchError(ContainSubstring(`invalid duration "soon"`)))
+		})
+	})
+
+	Describe("MergeFlags", func() {
+		var cfg cf_debug_server.DebugServerConfig
+
+		BeforeEach(func() {
+			cf_debug_server.AddConfigFlags(flags, "rep.")
+			cfg = cf_debug_server.DebugServerConfig{
+				DebugAddress:       "127.0.0.1:17008",
+				DebugAuthTokenFile: "/config/token",
+				DebugWriteTimeout:  cf_debug_server.Duration(10 * time.Minute),
+			}
+		})
+
+		It("prefers flags over environment variables over the config", func() {
+			GinkgoT().Setenv("REP_DEBUG_ADDRESS", "127.0.0.1:17009")
+			GinkgoT().Setenv("REP_DEBUG_WRITE_TIMEOUT", "20m")
+			Expect(flags.Parse([]string{
+				"-rep.debugAddr", "127.0.0.1:17010",
+				"-rep.debugAuthRequiredForReads",
+				"-rep.debugEnabledEndpoints", "/debug/pprof/, /log-level",
+			})).To(Succeed())
+
+			merged, err := cf_debug_server.MergeFlags(cfg, flags, "rep.")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(merged.DebugAddress).To(Equal("127.0.0.1:17010"))
+			Expect(merged.DebugWriteTimeout).To(Equal(cf_debug_server.Duration(20 * time.Minute)))
+			Expect(merged.DebugAuthTokenFile).To(Equal("/config/token"))
+			Expect(merged.DebugAuthRequiredForReads).To(BeTrue())
+			Expect(merged.DebugEnabledEndpoints).To(Equal([]string{"/debug/pprof/", "/log-level"}))
+		})
+
+		It("keeps the config when nothing is set", func() {
+			Expect(flags.Parse(nil)).To(Succeed())
+			merged, err := cf_debug_server.MergeFlags(cfg, flags, "rep.")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(merged).To(Equal(cfg))
+		})
+
+		It("names the environment variable with an invalid value", func() {
+			GinkgoT().Setenv("REP_DEBUG_FLIGHT_RECORDER_MAX_BYTES", "lots")
+			_, err := cf_debug_server.MergeFlags(cfg, flags, "rep.")
+			Expect(err).To(MatchError(ContainSubstring("environment variable REP_DEBUG_FLIGHT_RECORDER_MAX_BYTES")))
+		})
+	})
+})
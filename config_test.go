@@ -0,0 +1,204 @@
+package debugserver_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	cf_debug_server "code.cloudfoundry.org/debugserver"
+	lager "code.cloudfoundry.org/lager/v3"
+	"github.com/tedsuo/ifrit"
+	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DebugServerConfig", func() {
+	Describe("LoadDebugServerConfig", func() {
+		var dir string
+
+		BeforeEach(func() {
+			dir = GinkgoT().TempDir()
+		})
+
+		write := func(name, content string) string {
+			path := filepath.Join(dir, name)
+			Expect(os.WriteFile(path, []byte(content), 0600)).To(Succeed())
+			return path
+		}
+
+		It("loads JSON, ignoring the settings of the rest of the component", func() {
+			cfg, err := cf_debug_server.LoadDebugServerConfig(write("config.json", `{
+				"debug_address": "127.0.0.1:17002",
+				"debug_enabled_endpoints": ["/debug/pprof/", "/log-level"],
+				"debug_write_timeout": "10m",
+				"log_level": "info"
+			}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.DebugAddress).To(Equal("127.0.0.1:17002"))
+			Expect(cfg.DebugEnabledEndpoints).To(Equal([]string{"/debug/pprof/", "/log-level"}))
+			Expect(cfg.DebugWriteTimeout).To(Equal(cf_debug_server.Duration(10 * time.Minute)))
+		})
+
+		It("loads YAML with the same keys", func() {
+			cfg, err := cf_debug_server.LoadDebugServerConfig(write("config.yml", `
+debug_address: unix:///var/vcap/data/debug.sock
+debug_socket_mode: "0660"
+debug_auth_required_for_reads: true
+debug_max_profile_duration: 1m
+debug_flight_recorder_max_bytes: 1048576
+`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cfg.DebugAddress).To(Equal("unix:///var/vcap/data/debug.sock"))
+			Expect(cfg.DebugSocketMode).To(Equal("0660"))
+			Expect(cfg.DebugAuthRequiredForReads).To(BeTrue())
+			Expect(cfg.DebugMaxProfileDuration).To(Equal(cf_debug_server.Duration(time.Minute)))
+			Expect(cfg.DebugFlightRecorderMaxBytes).To(Equal(uint64(1048576)))
+		})
+
+		It("names the file in parse errors", func() {
+			path := write("config.json", `{"debug_read_timeout": "soon"}`)
+			_, err := cf_debug_server.LoadDebugServerConfig(path)
+			Expect(err).To(MatchError(ContainSubstring(path)))
+		})
+	})
+
+	Describe("WithDefaults", func() {
+		It("fills in the settings that are not set", func() {
+			cfg := cf_debug_server.DebugServerConfig{DebugIdleTimeout: cf_debug_server.Duration(time.Second)}.WithDefaults()
+			Expect(cfg.Timeouts()).To(Equal(cf_debug_server.Timeouts{
+				ReadHeader: 10 * time.Second,
+				Idle:       time.Second,
+				Shutdown:   time.Minute,
+			}))
+			Expect(cfg.DebugMaxProfileDuration).To(Equal(cf_debug_server.Duration(5 * time.Minute)))
+		})
+	})
+
+	Describe("Validate", func() {
+		It("accepts a complete config", func() {
+			Expect(cf_debug_server.DebugServerConfig{
+				DebugAddress:          "unix:///var/vcap/data/debug.sock",
+				DebugCertFile:         "/certs/server.crt",
+				DebugKeyFile:          "/certs/server.key",
+				DebugCACertFile:       "/certs/ca.crt",
+				DebugSocketMode:       "0660",
+				DebugAuthTokenFile:    "/secrets/token",
+				DebugEnabledEndpoints: []string{"/debug/pprof/", "/log-level"},
+				DebugWriteTimeout:     cf_debug_server.Duration(10 * time.Minute),
+			}.WithDefaults().Validate()).To(Succeed())
+		})
+
+		DescribeTable("rejects invalid settings",
+			func(cfg cf_debug_server.DebugServerConfig, message string) {
+				Expect(cfg.Validate()).To(MatchError(ContainSubstring(message)))
+			},
+			Entry("missing address", cf_debug_server.DebugServerConfig{}, "debug_address: is required"),
+			Entry("address without port", cf_debug_server.DebugServerConfig{DebugAddress: "localhost"}, "must be host:port"),
+			Entry("relative socket path", cf_debug_server.DebugServerConfig{DebugAddress: "unix://debug.sock"}, "absolute path"),
+			Entry("partial TLS files", cf_debug_server.DebugServerConfig{DebugAddress: "127.0.0.1:0", DebugCertFile: "/server.crt"}, "debug_ca_cert_file: is required"),
+			Entry("invalid socket mode", cf_debug_server.DebugServerConfig{DebugAddress: "unix:///debug.sock", DebugSocketMode: "rw"}, "debug_socket_mode"),
+			Entry("socket settings for TCP", cf_debug_server.DebugServerConfig{DebugAddress: "127.0.0.1:0", DebugSocketOwner: "vcap"}, "debug_socket_owner: only applies to unix:// addresses"),
+			Entry("auth for reads without a token", cf_debug_server.DebugServerConfig{DebugAddress: "127.0.0.1:0", DebugAuthRequiredForReads: true}, "requires debug_auth_token_file"),
+			Entry("invalid allowed network", cf_debug_server.DebugServerConfig{DebugAddress: "127.0.0.1:0", DebugAllowedNetworks: []string{"10.0.0.0/8", "vpn"}}, `debug_allowed_networks: "vpn" is neither a CIDR nor an IP address`),
+			Entry("strict policy on all interfaces", cf_debug_server.DebugServerConfig{DebugAddress: "0.0.0.0:17017", DebugStrictNetworkPolicy: true}, "debug_strict_network_policy only allows"),
+			Entry("unknown endpoint", cf_debug_server.DebugServerConfig{DebugAddress: "127.0.0.1:0", DebugEnabledEndpoints: []string{"/debug/vars"}}, `unknown endpoint "/debug/vars"`),
+			Entry("invalid log controller name", cf_debug_server.DebugServerConfig{DebugAddress: "127.0.0.1:0", DebugEnabledEndpoints: []string{"/log-level/a b"}}, `unknown endpoint "/log-level/a b"`),
+			Entry("negative timeout", cf_debug_server.DebugServerConfig{DebugAddress: "127.0.0.1:0", DebugIdleTimeout: -1}, "debug_idle_timeout: must not be negative"),
+			Entry("write timeout shorter than profiles", cf_debug_server.DebugServerConfig{DebugAddress: "127.0.0.1:0", DebugWriteTimeout: cf_debug_server.Duration(time.Minute)}, "debug_write_timeout"),
+		)
+
+		It("reports every invalid setting", func() {
+			err := cf_debug_server.DebugServerConfig{
+				DebugAddress:     "localhost",
+				DebugSocketGroup: "vcap",
+			}.Validate()
+			Expect(err).To(MatchError(ContainSubstring("debug_address")))
+			Expect(err).To(MatchError(ContainSubstring("debug_socket_group")))
+		})
+
+		It("accepts /log-level/NAME endpoints, whose controllers are passed as options", func() {
+			Expect(cf_debug_server.DebugServerConfig{
+				DebugAddress:          "127.0.0.1:0",
+				DebugEnabledEndpoints: []string{"/log-level/access"},
+			}.Validate()).To(Succeed())
+		})
+	})
+
+	Describe("NewFromConfig", func() {
+		var (
+			sink    *lager.ReconfigurableSink
+			process ifrit.Process
+		)
+
+		BeforeEach(func() {
+			sink = lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.INFO)
+			process = nil
+		})
+
+		AfterEach(func() {
+			if process != nil {
+				ginkgomon.Interrupt(process)
+			}
+		})
+
+		It("runs the server described by the config", func() {
+			server, err := cf_debug_server.NewFromConfig(cf_debug_server.DebugServerConfig{
+				DebugAddress:            address,
+				DebugEnabledEndpoints:   []string{"/debug/pprof/profile", "/log-level"},
+				DebugMaxProfileDuration: cf_debug_server.Duration(time.Second),
+			}, sink)
+			Expect(err).NotTo(HaveOccurred())
+			process = ginkgomon.Invoke(server)
+
+			get := func(path string) (int, string) {
+				resp, err := http.Get(fmt.Sprintf("http://%s%s", address, path))
+				Expect(err).NotTo(HaveOccurred())
+				defer resp.Body.Close()
+				body, err := io.ReadAll(resp.Body)
+				Expect(err).NotTo(HaveOccurred())
+				return resp.StatusCode, string(body)
+			}
+
+			status, _ := get("/log-level")
+			Expect(status).To(Equal(http.StatusOK))
+
+			status, body := get("/debug/pprof/profile?seconds=2")
+			Expect(status).To(Equal(http.StatusBadRequest))
+			Expect(body).To(ContainSubstring("exceeds the maximum profile duration of 1s"))
+
+			status, _ = get("/debug/metrics")
+			Expect(status).To(Equal(http.StatusNotFound))
+		})
+
+		It("enables the endpoints of named log controllers passed as options", func() {
+			accessSink := lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.INFO)
+			server, err := cf_debug_server.NewFromConfig(cf_debug_server.DebugServerConfig{
+				DebugAddress:          address,
+				DebugEnabledEndpoints: []string{"/log-level/access"},
+			}, sink, cf_debug_server.WithNamedLogController("access", accessSink))
+			Expect(err).NotTo(HaveOccurred())
+
+			rec := serveRequest(server, http.MethodGet, "/log-level/access", "")
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(serveRequest(server, http.MethodGet, "/log-level", "").Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("refuses /log-level/NAME endpoints without a controller of that name", func() {
+			_, err := cf_debug_server.NewFromConfig(cf_debug_server.DebugServerConfig{
+				DebugAddress:          address,
+				DebugEnabledEndpoints: []string{"/log-level/access"},
+			}, sink)
+			Expect(err).To(MatchError(ContainSubstring("unknown debug endpoint /log-level/access")))
+		})
+
+		It("returns validation errors instead of running", func() {
+			_, err := cf_debug_server.NewFromConfig(cf_debug_server.DebugServerConfig{}, sink)
+			Expect(err).To(MatchError(ContainSubstring("invalid debug server config")))
+		})
+	})
+})
@@ -0,0 +1,102 @@
+package debugserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Error codes of control endpoints in JSON mode.
+const (
+	errorCodeMethodNotAllowed = "method_not_allowed"
+	errorCodeInvalidBody      = "invalid_body"
+	errorCodeInvalidValue     = "invalid_value"
+	errorCodeNotImplemented   = "not_implemented"
+	errorCodeUnauthorized     = "unauthorized"
+	errorCodeForbidden        = "forbidden"
+	errorCodeInternal         = "internal_error"
+)
+
+// controlError is a request that a control endpoint refused. In JSON mode it is written
+// as {"error":{"code":"invalid_value","message":"..."}}, otherwise as the plain message.
+type controlError struct {
+	status  int
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *controlError) Error() string {
+	return e.Message
+}
+
+func invalidBody(format string, args ...interface{}) *controlError {
+	return &controlError{http.StatusBadRequest, errorCodeInvalidBody, fmt.Sprintf(format, args...)}
+}
+
+func invalidValue(format string, args ...interface{}) *controlError {
+	return &controlError{http.StatusBadRequest, errorCodeInvalidValue, fmt.Sprintf(format, args...)}
+}
+
+func methodNotAllowed(message string) *controlError {
+	return &controlError{http.StatusMethodNotAllowed, errorCodeMethodNotAllowed, message}
+}
+
+func notImplemented(message string) *controlError {
+	return &controlError{http.StatusNotImplemented, errorCodeNotImplemented, message}
+}
+
+func unauthorized(message string) *controlError {
+	return &controlError{http.StatusUnauthorized, errorCodeUnauthorized, message}
+}
+
+func forbidden(message string) *controlError {
+	return &controlError{http.StatusForbidden, errorCodeForbidden, message}
+}
+
+func internalError(message string) *controlError {
+	return &controlError{http.StatusInternalServerError, errorCodeInternal, message}
+}
+
+// controlChange is the JSON response of a control endpoint that changed a setting.
+type controlChange[T any] struct {
+	Previous T `json:"previous"`
+	Current  T `json:"current"`
+}
+
+// hasJSONBody reports whether the request body is JSON.
+func hasJSONBody(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+}
+
+// jsonControlMode reports whether a control endpoint reads and answers JSON, which it
+// does when the request has a JSON body or accepts a JSON response.
+func jsonControlMode(r *http.Request) bool {
+	return hasJSONBody(r) || wantsJSON(r)
+}
+
+// writeControlError writes err with the status of a controlError, or 400 Bad Request
+// for other errors.
+func writeControlError(w http.ResponseWriter, r *http.Request, err error) {
+	var ctrlErr *controlError
+	if !errors.As(err, &ctrlErr) {
+		ctrlErr = invalidBody("%s", err.Error())
+	}
+	if !jsonControlMode(r) {
+		http.Error(w, ctrlErr.Message, ctrlErr.status)
+		return
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	writeJSON(w, ctrlErr.status, struct {
+		Error *controlError `json:"error"`
+	}{ctrlErr})
+}
+
+// writeJSON writes value as the JSON response of an endpoint, with status.
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	// #nosec G104 - ignore errors writing http response to avoid spamming logs during DoS
+	json.NewEncoder(w).Encode(value)
+}
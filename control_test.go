@@ -0,0 +1,132 @@
+package debugserver_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+
+	cf_debug_server "code.cloudfoundry.org/debugserver"
+	lager "code.cloudfoundry.org/lager/v3"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JSON mode of control endpoints", func() {
+	var (
+		sink    *lager.ReconfigurableSink
+		handler http.Handler
+	)
+
+	BeforeEach(func() {
+		sink = lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.INFO)
+		handler = cf_debug_server.Handler(sink)
+	})
+
+	serveJSON := func(method, path, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		rec := serveRequest(handler, method, path, body, "Content-Type", "application/json")
+		Expect(rec.Header().Get("Content-Type")).To(Equal("application/json"))
+		var response map[string]interface{}
+		Expect(json.Unmarshal(rec.Body.Bytes(), &response)).To(Succeed())
+		return rec, response
+	}
+
+	It("reports the previous and current log level", func() {
+		rec, response := serveJSON(http.MethodPost, "/log-level", `{"level":"debug"}`)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(response).To(Equal(map[string]interface{}{"previous": "info", "current": "debug"}))
+	})
+
+	It("reports the current log level like the other settings", func() {
+		rec, response := serveJSON(http.MethodGet, "/log-level", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(response).To(Equal(map[string]interface{}{"current": "info"}))
+	})
+
+	It("reports the previous and current level of every log controller", func() {
+		rec, response := serveJSON(http.MethodPost, "/log-levels", `{"level":"debug"}`)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(response).To(Equal(map[string]interface{}{
+			"log_levels": []interface{}{
+				map[string]interface{}{"path": "/log-level", "previous": "info", "current": "debug"},
+			},
+		}))
+	})
+
+	It("reports the previous and current block profile rate", func() {
+		DeferCleanup(runtime.SetBlockProfileRate, 0)
+
+		_, response := serveJSON(http.MethodPost, "/block-profile-rate", `{"value":5}`)
+		Expect(response).To(HaveKeyWithValue("current", BeNumerically("==", 5)))
+
+		rec, response := serveJSON(http.MethodPut, "/block-profile-rate", `{"value":0}`)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(response).To(Equal(map[string]interface{}{"previous": 5.0, "current": 0.0}))
+	})
+
+	It("reports the previous and current mutex profile fraction", func() {
+		DeferCleanup(runtime.SetMutexProfileFraction, runtime.SetMutexProfileFraction(3))
+
+		_, response := serveJSON(http.MethodPost, "/mutex-profile-fraction", `{"value":-1}`)
+		Expect(response).To(Equal(map[string]interface{}{"previous": 3.0, "current": 0.0}))
+	})
+
+	It("reports the previous and current GOGC", func() {
+		DeferCleanup(debug.SetGCPercent, debug.SetGCPercent(100))
+
+		_, response := serveJSON(http.MethodPost, "/gc-percent", `{"value":50}`)
+		Expect(response).To(Equal(map[string]interface{}{"previous": 100.0, "current": 50.0}))
+	})
+
+	DescribeTable("answers errors with a code and message",
+		func(method, path, body string, status int, code string) {
+			rec, response := serveJSON(method, path, body)
+			Expect(rec.Code).To(Equal(status))
+			Expect(response).To(HaveKeyWithValue("error", HaveKeyWithValue("code", code)))
+			Expect(response).To(HaveKeyWithValue("error", HaveKeyWithValue("message", Not(BeEmpty()))))
+		},
+		Entry("unknown log level", http.MethodPost, "/log-level", `{"level":"loud"}`, http.StatusBadRequest, "invalid_value"),
+		Entry("malformed body", http.MethodPost, "/log-level", `{"level":`, http.StatusBadRequest, "invalid_body"),
+		Entry("invalid duration", http.MethodPost, "/log-levels", `{"level":"debug","ttl":"soon"}`, http.StatusBadRequest, "invalid_value"),
+		Entry("missing value", http.MethodPost, "/block-profile-rate", `{"rate":5}`, http.StatusBadRequest, "invalid_body"),
+		Entry("wrong method", http.MethodDelete, "/log-level", ``, http.StatusMethodNotAllowed, "method_not_allowed"),
+		Entry("wrong method on /gc", http.MethodGet, "/gc", ``, http.StatusMethodNotAllowed, "method_not_allowed"),
+	)
+
+	It("answers token auth refusals with a code and message", func() {
+		tokenFile := filepath.Join(GinkgoT().TempDir(), "token")
+		Expect(os.WriteFile(tokenFile, []byte("s3cret"), 0600)).To(Succeed())
+		handler = cf_debug_server.Handler(sink, cf_debug_server.WithTokenAuth(tokenFile, cf_debug_server.AuthControlEndpoints))
+
+		rec, response := serveJSON(http.MethodPost, "/log-level", `{"level":"debug"}`)
+		Expect(rec.Code).To(Equal(http.StatusUnauthorized))
+		Expect(rec.Header().Get("WWW-Authenticate")).NotTo(BeEmpty())
+		Expect(response).To(Equal(map[string]interface{}{"error": map[string]interface{}{"code": "unauthorized", "message": "missing bearer token"}}))
+	})
+
+	It("answers network policy refusals with a code and message", func() {
+		handler = cf_debug_server.Handler(sink, cf_debug_server.WithAllowedNetworks("10.0.0.0/8"))
+
+		rec, response := serveJSON(http.MethodPost, "/log-level", `{"level":"debug"}`)
+		Expect(rec.Code).To(Equal(http.StatusForbidden))
+		Expect(response).To(HaveKeyWithValue("error", HaveKeyWithValue("code", "forbidden")))
+		Expect(sink.GetMinLevel()).To(Equal(lager.INFO))
+	})
+
+	It("answers in JSON when the client only accepts JSON", func() {
+		rec := serveRequest(handler, http.MethodPost, "/mutex-profile-fraction", "fast", "Accept", "application/json")
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		Expect(rec.Body.String()).To(MatchJSON(`{"error":{"code":"invalid_value","message":"strconv.ParseInt: parsing \"fast\": invalid syntax"}}`))
+	})
+
+	It("keeps answering text requests with text", func() {
+		rec := serveRequest(handler, http.MethodPost, "/log-level", "loud")
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		Expect(rec.Body.String()).To(Equal("invalid log level: loud\n"))
+	})
+})
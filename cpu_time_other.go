@@ -0,0 +1,13 @@
+//go:build !unix
+
+package debugserver
+
+import (
+	"errors"
+	"time"
+)
+
+// processCPUTime is not implemented on this platform, so CPU thresholds never trigger.
+func processCPUTime() (time.Duration, error) {
+	return 0, errors.New("process CPU time is not supported on this platform")
+}
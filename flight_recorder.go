@@ -0,0 +1,113 @@
+package debugserver
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime/trace"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultFlightRecorderWindow is the window the runtime keeps when none is configured.
+const defaultFlightRecorderWindow = 10 * time.Second
+
+// FlightRecorderConfig configures the rolling execution trace kept by a FlightRecorder.
+type FlightRecorderConfig struct {
+	// Window is how far back the recorded trace reaches. Zero uses the runtime default,
+	// which is on the order of seconds.
+	Window time.Duration
+	// MaxBytes bounds the size of the window and takes precedence over Window.
+	// Zero uses the runtime default.
+	MaxBytes uint64
+}
+
+// FlightRecorder keeps a rolling window of execution trace in memory, so that the
+// moments before a latency spike can be inspected after the fact.
+//
+// A FlightRecorder is an ifrit.Runner: recording starts when it is run and stops when
+// it is signalled. Passing it to Runner with WithFlightRecorder runs it alongside the
+// debug server. Only one flight recorder can be active in a process.
+type FlightRecorder struct {
+	recorder *trace.FlightRecorder
+	window   time.Duration
+
+	// mu serializes snapshots, since the runtime only allows one WriteTo at a time.
+	mu sync.Mutex
+}
+
+// NewFlightRecorder creates a flight recorder that is not recording yet.
+func NewFlightRecorder(cfg FlightRecorderConfig) *FlightRecorder {
+	window := cfg.Window
+	if window <= 0 {
+		window = defaultFlightRecorderWindow
+	}
+	return &FlightRecorder{
+		recorder: trace.NewFlightRecorder(trace.FlightRecorderConfig{
+			MinAge:   cfg.Window,
+			MaxBytes: cfg.MaxBytes,
+		}),
+		window: window,
+	}
+}
+
+// WithFlightRecorder serves snapshots of the flight recorder on /debug/flight-recorder
+// and, when used with Runner, records for as long as the debug server runs.
+func WithFlightRecorder(recorder *FlightRecorder) Option {
+	return func(o *options) {
+		if recorder == nil {
+			return
+		}
+		o.flightRecorder = recorder
+		o.sidecars = append(o.sidecars, recorder)
+	}
+}
+
+func (f *FlightRecorder) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	if err := f.recorder.Start(); err != nil {
+		return err
+	}
+	defer f.recorder.Stop()
+
+	close(ready)
+	<-signals
+	return nil
+}
+
+// ServeHTTP responds with a snapshot of the recorded window in the execution trace
+// format understood by go tool trace. ?seconds=n asks for at least the last n seconds
+// and is refused when the window is shorter. The runtime cannot cut a snapshot short,
+// so the response always holds the whole window.
+func (f *FlightRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !f.recorder.Enabled() {
+		http.Error(w, "flight recorder is not running", http.StatusServiceUnavailable)
+		return
+	}
+	if value := r.FormValue("seconds"); value != "" {
+		seconds, err := strconv.ParseFloat(value, 64)
+		if err != nil || seconds <= 0 {
+			http.Error(w, "invalid seconds: "+value, http.StatusBadRequest)
+			return
+		}
+		if requested := time.Duration(seconds * float64(time.Second)); requested > f.window {
+			http.Error(w, fmt.Sprintf("seconds=%s exceeds the flight recorder window of %s", value, f.window), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var snapshot bytes.Buffer
+	f.mu.Lock()
+	_, err := f.recorder.WriteTo(&snapshot)
+	f.mu.Unlock()
+	if err != nil {
+		http.Error(w, "failed to snapshot flight recorder: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="flight-recorder.trace"`)
+	// #nosec G104 - ignore errors writing http response to avoid spamming logs during DoS
+	snapshot.WriteTo(w)
+}
@@ -0,0 +1,117 @@
+package debugserver_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	cf_debug_server "code.cloudfoundry.org/debugserver"
+	lager "code.cloudfoundry.org/lager/v3"
+	"github.com/tedsuo/ifrit"
+	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Flight recorder", func() {
+	var (
+		sink     *lager.ReconfigurableSink
+		recorder *cf_debug_server.FlightRecorder
+
+		process ifrit.Process
+	)
+
+	BeforeEach(func() {
+		sink = lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.INFO)
+		recorder = cf_debug_server.NewFlightRecorder(cf_debug_server.FlightRecorderConfig{
+			Window:   time.Second,
+			MaxBytes: 1 << 20,
+		})
+	})
+
+	AfterEach(func() {
+		ginkgomon.Interrupt(process)
+	})
+
+	Context("when run with the debug server", func() {
+		BeforeEach(func() {
+			process = ginkgomon.Invoke(cf_debug_server.Runner(address, sink, cf_debug_server.WithFlightRecorder(recorder)))
+		})
+
+		It("serves a snapshot of the execution trace", func() {
+			resp, err := http.Get(fmt.Sprintf("http://%s/debug/flight-recorder", address))
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(resp.Header.Get("Content-Type")).To(Equal("application/octet-stream"))
+			body, err := io.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(body)).To(HavePrefix("go 1."))
+		})
+
+		It("serves a snapshot that covers the requested seconds", func() {
+			resp, err := http.Get(fmt.Sprintf("http://%s/debug/flight-recorder?seconds=0.5", address))
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			_, err = io.Copy(io.Discard, resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		})
+
+		It("refuses more seconds than the window holds", func() {
+			resp, err := http.Get(fmt.Sprintf("http://%s/debug/flight-recorder?seconds=5", address))
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+			Expect(string(body)).To(ContainSubstring("exceeds the flight recorder window of 1s"))
+		})
+
+		It("refuses to start a second flight recorder", func() {
+			second := cf_debug_server.NewFlightRecorder(cf_debug_server.FlightRecorderConfig{})
+			p := ifrit.Invoke(cf_debug_server.Runner("127.0.0.1:0", sink, cf_debug_server.WithFlightRecorder(second)))
+			Eventually(p.Wait()).Should(Receive(HaveOccurred()))
+		})
+	})
+
+	Context("when the recorder is not running", func() {
+		It("responds with service unavailable", func() {
+			handler := cf_debug_server.Handler(sink, cf_debug_server.WithFlightRecorder(recorder))
+			rec := serveRequest(handler, http.MethodGet, "/debug/flight-recorder", "")
+			Expect(rec.Code).To(Equal(http.StatusServiceUnavailable))
+		})
+	})
+
+	It("is not served unless configured", func() {
+		rec := serveRequest(cf_debug_server.Handler(sink), http.MethodGet, "/debug/flight-recorder", "")
+		Expect(rec.Code).To(Equal(http.StatusNotFound))
+	})
+
+	Describe("DebugServerConfig", func() {
+		It("reads the flight recorder settings from JSON", func() {
+			var cfg cf_debug_server.DebugServerConfig
+			Expect(json.Unmarshal([]byte(`{
+				"debug_address": "127.0.0.1:17017",
+				"debug_flight_recorder_enabled": true,
+				"debug_flight_recorder_window": "10s",
+				"debug_flight_recorder_max_bytes": 1048576
+			}`), &cfg)).To(Succeed())
+
+			Expect(cfg.DebugFlightRecorderEnabled).To(BeTrue())
+			Expect(time.Duration(cfg.DebugFlightRecorderWindow)).To(Equal(10 * time.Second))
+			Expect(cfg.DebugFlightRecorderMaxBytes).To(BeEquivalentTo(1 << 20))
+			Expect(cfg.HandlerOptions()).To(HaveLen(1))
+		})
+
+		It("writes durations as strings", func() {
+			data, err := json.Marshal(cf_debug_server.DebugServerConfig{DebugFlightRecorderWindow: cf_debug_server.Duration(time.Minute)})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(ContainSubstring(`"debug_flight_recorder_window":"1m0s"`))
+		})
+	})
+})
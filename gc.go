@@ -0,0 +1,200 @@
+package debugserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"strconv"
+
+	lager "code.cloudfoundry.org/lager/v3"
+)
+
+const (
+	gcPercentMetric     = "/gc/gogc:percent"
+	heapObjectsMetric   = "/memory/classes/heap/objects:bytes"
+	heapReleasedMetric  = "/memory/classes/heap/released:bytes"
+	heapUnusedMetric    = "/memory/classes/heap/unused:bytes"
+	noMemoryLimit       = math.MaxInt64
+	methodNotAllowedMsg = "method not allowed, use GET to read or POST to set"
+)
+
+// gcPercentHandler reads the GOGC value on GET and sets it on POST or PUT,
+// responding with the previous value. Pass a value < 0 to turn off the collector.
+func gcPercentHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			// The metric reports a disabled collector (-1) as its two's complement.
+			writeCurrent(w, r, int64(readUint64Metric(gcPercentMetric)))
+			return
+		}
+		if !isWriteMethod(r) {
+			writeControlError(w, r, methodNotAllowed(methodNotAllowedMsg))
+			return
+		}
+
+		percent, ok := readIntBody(w, r)
+		if !ok {
+			return
+		}
+		if percent < 0 {
+			percent = -1
+		}
+
+		previous := debug.SetGCPercent(int(percent))
+		addAuditParams(r, lager.Data{"previous": previous, "gc_percent": percent})
+		writeChange(w, r, int64(previous), percent)
+	}
+}
+
+// memoryLimitHandler reads the soft memory limit on GET and sets it on POST or PUT,
+// responding with the previous value. Pass a value < 0 to remove the limit.
+func memoryLimitHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			writeCurrent(w, r, debug.SetMemoryLimit(-1))
+			return
+		}
+		if !isWriteMethod(r) {
+			writeControlError(w, r, methodNotAllowed(methodNotAllowedMsg))
+			return
+		}
+
+		limit, ok := readIntBody(w, r)
+		if !ok {
+			return
+		}
+		if limit < 0 {
+			limit = noMemoryLimit
+		}
+
+		previous := debug.SetMemoryLimit(limit)
+		addAuditParams(r, lager.Data{"previous": previous, "memory_limit": limit})
+		writeChange(w, r, previous, limit)
+	}
+}
+
+// gcHandler runs a garbage collection and reports the heap object bytes before and after.
+func gcHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isWriteMethod(r) {
+			writeControlError(w, r, methodNotAllowed("method not allowed, use POST"))
+			return
+		}
+
+		before := readUint64Metric(heapObjectsMetric)
+		runtime.GC()
+		after := readUint64Metric(heapObjectsMetric)
+
+		addAuditParams(r, lager.Data{"heap_objects_bytes_before": before, "heap_objects_bytes_after": after})
+		if jsonControlMode(r) {
+			writeJSON(w, http.StatusOK, controlChange[uint64]{before, after})
+			return
+		}
+		// #nosec G104 - ignore errors writing http response to avoid spamming logs during DoS
+		fmt.Fprintf(w, "heap objects bytes before: %d\nheap objects bytes after: %d\n", before, after)
+	}
+}
+
+// freeOSMemoryHandler forces a garbage collection and returns as much memory to the
+// operating system as possible, reporting the released heap bytes before and after.
+func freeOSMemoryHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isWriteMethod(r) {
+			writeControlError(w, r, methodNotAllowed("method not allowed, use POST"))
+			return
+		}
+
+		before := readUint64Metric(heapReleasedMetric)
+		debug.FreeOSMemory()
+		after := readUint64Metric(heapReleasedMetric)
+
+		addAuditParams(r, lager.Data{"heap_released_bytes_before": before, "heap_released_bytes_after": after})
+		if jsonControlMode(r) {
+			writeJSON(w, http.StatusOK, controlChange[uint64]{before, after})
+			return
+		}
+		// #nosec G104 - ignore errors writing http response to avoid spamming logs during DoS
+		fmt.Fprintf(w, "heap released bytes before: %d\nheap released bytes after: %d\n", before, after)
+	}
+}
+
+func isWriteMethod(r *http.Request) bool {
+	return r.Method == http.MethodPost || r.Method == http.MethodPut
+}
+
+// readIntBody parses the request body as an integer, or as {"value": n} when it is JSON,
+// writing a 400 response if it is not one.
+func readIntBody(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	value, err := parseIntBody(r)
+	if err != nil {
+		writeControlError(w, r, err)
+		return 0, false
+	}
+	return value, true
+}
+
+func parseIntBody(r *http.Request) (int64, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return 0, invalidBody("Failed to read body")
+	}
+	if hasJSONBody(r) {
+		var req struct {
+			Value *int64 `json:"value"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			return 0, invalidBody("invalid JSON body: %s", err)
+		}
+		if req.Value == nil {
+			return 0, invalidBody("invalid JSON body: value is required")
+		}
+		return *req.Value, nil
+	}
+
+	value, err := strconv.ParseInt(string(body), 10, 64)
+	if err != nil {
+		return 0, invalidValue("%s", err)
+	}
+	return value, nil
+}
+
+func writeValue[T int | int64 | uint64](w http.ResponseWriter, value T) {
+	w.Header().Set("Content-Type", "text/plain")
+	// #nosec G104 - ignore errors writing http response to avoid spamming logs during DoS
+	fmt.Fprintf(w, "%d\n", value)
+}
+
+// writeCurrent responds to a GET with the value, or with {"current": value} in JSON mode.
+func writeCurrent[T int | int64 | uint64](w http.ResponseWriter, r *http.Request, value T) {
+	if jsonControlMode(r) {
+		writeJSON(w, http.StatusOK, struct {
+			Current T `json:"current"`
+		}{value})
+		return
+	}
+	writeValue(w, value)
+}
+
+// writeChange responds to a change of a setting with the previous value, or with the
+// previous and current values in JSON mode.
+func writeChange[T int | int64 | uint64](w http.ResponseWriter, r *http.Request, previous, current T) {
+	if jsonControlMode(r) {
+		writeJSON(w, http.StatusOK, controlChange[T]{previous, current})
+		return
+	}
+	writeValue(w, previous)
+}
+
+func readUint64Metric(name string) uint64 {
+	sample := []metrics.Sample{{Name: name}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
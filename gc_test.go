@@ -0,0 +1,108 @@
+package debugserver_test
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+
+	cf_debug_server "code.cloudfoundry.org/debugserver"
+	lager "code.cloudfoundry.org/lager/v3"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GC controls", func() {
+	var handler http.Handler
+
+	BeforeEach(func() {
+		sink := lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.INFO)
+		handler = cf_debug_server.Handler(sink)
+	})
+
+	Describe("/gc-percent", func() {
+		var original int
+
+		BeforeEach(func() {
+			original = debug.SetGCPercent(100)
+			DeferCleanup(func() { debug.SetGCPercent(original) })
+		})
+
+		It("reads the current value", func() {
+			rec := serveRequest(handler, http.MethodGet, "/gc-percent", "")
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Body.String()).To(Equal("100\n"))
+		})
+
+		It("sets the value and returns the previous one", func() {
+			rec := serveRequest(handler, http.MethodPost, "/gc-percent", "50")
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Body.String()).To(Equal("100\n"))
+			Expect(serveRequest(handler, http.MethodGet, "/gc-percent", "").Body.String()).To(Equal("50\n"))
+		})
+
+		It("turns off the collector for negative values", func() {
+			Expect(serveRequest(handler, http.MethodPut, "/gc-percent", "-20").Code).To(Equal(http.StatusOK))
+			Expect(serveRequest(handler, http.MethodGet, "/gc-percent", "").Body.String()).To(Equal("-1\n"))
+		})
+
+		It("rejects values that are not integers", func() {
+			rec := serveRequest(handler, http.MethodPost, "/gc-percent", "lots")
+			Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("rejects other methods", func() {
+			rec := serveRequest(handler, http.MethodDelete, "/gc-percent", "")
+			Expect(rec.Code).To(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+
+	Describe("/memory-limit", func() {
+		var original int64
+
+		BeforeEach(func() {
+			original = debug.SetMemoryLimit(math.MaxInt64)
+			DeferCleanup(func() { debug.SetMemoryLimit(original) })
+		})
+
+		It("sets the limit and returns the previous one", func() {
+			rec := serveRequest(handler, http.MethodPost, "/memory-limit", "1073741824")
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Body.String()).To(Equal(strconv.FormatInt(math.MaxInt64, 10) + "\n"))
+			Expect(serveRequest(handler, http.MethodGet, "/memory-limit", "").Body.String()).To(Equal("1073741824\n"))
+		})
+
+		It("removes the limit for negative values", func() {
+			debug.SetMemoryLimit(1 << 30)
+			Expect(serveRequest(handler, http.MethodPost, "/memory-limit", "-1").Code).To(Equal(http.StatusOK))
+			Expect(debug.SetMemoryLimit(-1)).To(Equal(int64(math.MaxInt64)))
+		})
+
+		It("rejects values that are not integers", func() {
+			rec := serveRequest(handler, http.MethodPost, "/memory-limit", "1GiB")
+			Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Describe("/gc", func() {
+		It("runs a collection", func() {
+			rec := serveRequest(handler, http.MethodPost, "/gc", "")
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Body.String()).To(MatchRegexp(`heap objects bytes before: \d+\nheap objects bytes after: \d+\n`))
+		})
+
+		It("rejects GET", func() {
+			Expect(serveRequest(handler, http.MethodGet, "/gc", "").Code).To(Equal(http.StatusMethodNotAllowed))
+		})
+	})
+
+	Describe("/free-os-memory", func() {
+		It("returns memory to the operating system", func() {
+			rec := serveRequest(handler, http.MethodPost, "/free-os-memory", "")
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Body.String()).To(MatchRegexp(`heap released bytes before: \d+\nheap released bytes after: \d+\n`))
+		})
+	})
+})
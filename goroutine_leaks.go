@@ -0,0 +1,196 @@
+package debugserver
+
+import (
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	lager "code.cloudfoundry.org/lager/v3"
+)
+
+const (
+	defaultGoroutineLeakInterval  = time.Minute
+	defaultGoroutineLeakIntervals = 5
+)
+
+// GoroutineLeakDetectorConfig configures how often a GoroutineLeakDetector looks at the
+// goroutines and how long a stack has to grow before it is reported.
+type GoroutineLeakDetectorConfig struct {
+	// Interval between two counts of the goroutines per stack. Defaults to 1m.
+	Interval time.Duration
+	// Intervals is how many intervals in a row the number of goroutines with the same
+	// stack has to grow before the stack is reported. Defaults to 5.
+	Intervals int
+}
+
+// GoroutineLeakDetector counts the goroutines per stack at every interval and reports
+// stacks whose count grew in each of the last few intervals, which is what a slow
+// goroutine leak looks like. Suspects are logged when they are first seen and are
+// served on /debug/goroutines/leaks.
+//
+// A GoroutineLeakDetector is an ifrit.Runner. Passing it to Runner with
+// WithGoroutineLeakDetector runs it alongside the debug server.
+type GoroutineLeakDetector struct {
+	logger lager.Logger
+	cfg    GoroutineLeakDetectorConfig
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	stacks    map[string]*goroutineStackHistory
+}
+
+// goroutineStackHistory is the goroutine count of one stack over the last intervals.
+type goroutineStackHistory struct {
+	stack          *goroutineStack
+	counts         []int
+	suspectedSince time.Time
+}
+
+// goroutineLeakSuspect is a stack whose goroutine count kept growing.
+type goroutineLeakSuspect struct {
+	Counts         []int            `json:"counts"`
+	SuspectedSince time.Time        `json:"suspected_since"`
+	Frames         []goroutineFrame `json:"frames"`
+	CreatedBy      *goroutineFrame  `json:"created_by,omitempty"`
+}
+
+// NewGoroutineLeakDetector creates a leak detector that logs suspected leaks to logger.
+func NewGoroutineLeakDetector(logger lager.Logger, cfg GoroutineLeakDetectorConfig) *GoroutineLeakDetector {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultGoroutineLeakInterval
+	}
+	if cfg.Intervals <= 0 {
+		cfg.Intervals = defaultGoroutineLeakIntervals
+	}
+	return &GoroutineLeakDetector{
+		logger: logger.Session("debug-server-goroutine-leaks"),
+		cfg:    cfg,
+		stacks: map[string]*goroutineStackHistory{},
+	}
+}
+
+// WithGoroutineLeakDetector serves the suspected leaks on /debug/goroutines/leaks and,
+// when used with Runner, runs the detector for as long as the debug server runs.
+func WithGoroutineLeakDetector(detector *GoroutineLeakDetector) Option {
+	return func(o *options) {
+		if detector == nil {
+			return
+		}
+		o.leakDetector = detector
+		o.sidecars = append(o.sidecars, detector)
+	}
+}
+
+func (d *GoroutineLeakDetector) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	ticker := time.NewTicker(d.cfg.Interval)
+	defer ticker.Stop()
+
+	d.check(time.Now())
+	close(ready)
+
+	for {
+		select {
+		case <-signals:
+			return nil
+		case now := <-ticker.C:
+			d.check(now)
+		}
+	}
+}
+
+// check counts the goroutines per stack and updates the suspects.
+func (d *GoroutineLeakDetector) check(now time.Time) {
+	goroutines, err := dumpGoroutines()
+	if err != nil {
+		d.logger.Error("failed-to-dump-goroutines", err)
+		return
+	}
+	current := countGoroutineStacks(goroutines)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.checkedAt = now.UTC()
+
+	for key := range d.stacks {
+		if _, ok := current[key]; !ok {
+			delete(d.stacks, key)
+		}
+	}
+	for key, stack := range current {
+		history, ok := d.stacks[key]
+		if !ok {
+			history = &goroutineStackHistory{}
+			d.stacks[key] = history
+		}
+		history.stack = stack
+		history.counts = append(history.counts, stack.Count)
+		if len(history.counts) > d.cfg.Intervals+1 {
+			history.counts = history.counts[1:]
+		}
+
+		if !history.growing(d.cfg.Intervals) {
+			history.suspectedSince = time.Time{}
+			continue
+		}
+		if history.suspectedSince.IsZero() {
+			history.suspectedSince = now.UTC()
+			data := lager.Data{"counts": history.counts}
+			if len(stack.Frames) > 0 {
+				data["function"] = stack.Frames[0].Function
+			}
+			if stack.CreatedBy != nil {
+				data["created_by"] = stack.CreatedBy.Function
+			}
+			d.logger.Info("goroutine-leak-suspected", data)
+		}
+	}
+}
+
+// growing reports whether the count grew in each of the last intervals.
+func (h *goroutineStackHistory) growing(intervals int) bool {
+	if len(h.counts) < intervals+1 {
+		return false
+	}
+	for i := 1; i < len(h.counts); i++ {
+		if h.counts[i] <= h.counts[i-1] {
+			return false
+		}
+	}
+	return true
+}
+
+// ServeHTTP responds with the current suspects as JSON, largest first.
+func (d *GoroutineLeakDetector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	checkedAt := d.checkedAt
+	suspects := []*goroutineLeakSuspect{}
+	for _, history := range d.stacks {
+		if history.suspectedSince.IsZero() {
+			continue
+		}
+		suspects = append(suspects, &goroutineLeakSuspect{
+			Counts:         append([]int(nil), history.counts...),
+			SuspectedSince: history.suspectedSince,
+			Frames:         history.stack.Frames,
+			CreatedBy:      history.stack.CreatedBy,
+		})
+	}
+	d.mu.Unlock()
+
+	if checkedAt.IsZero() {
+		http.Error(w, "goroutine leak detector is not running", http.StatusServiceUnavailable)
+		return
+	}
+	sort.Slice(suspects, func(i, j int) bool {
+		return suspects[i].Counts[len(suspects[i].Counts)-1] > suspects[j].Counts[len(suspects[j].Counts)-1]
+	})
+
+	writeJSON(w, http.StatusOK, struct {
+		CheckedAt time.Time               `json:"checked_at"`
+		Interval  Duration                `json:"interval"`
+		Intervals int                     `json:"intervals"`
+		Suspects  []*goroutineLeakSuspect `json:"suspects"`
+	}{checkedAt, Duration(d.cfg.Interval), d.cfg.Intervals, suspects})
+}
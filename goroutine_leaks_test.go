@@ -0,0 +1,110 @@
+package debugserver_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	cf_debug_server "code.cloudfoundry.org/debugserver"
+	lager "code.cloudfoundry.org/lager/v3"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/tedsuo/ifrit"
+	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func leakGoroutines(release, stop chan struct{}) {
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			go parkOnChannel(release)
+		}
+	}
+}
+
+var _ = Describe("GoroutineLeakDetector", func() {
+	var (
+		logs     *gbytes.Buffer
+		detector *cf_debug_server.GoroutineLeakDetector
+		handler  http.Handler
+		release  chan struct{}
+		process  ifrit.Process
+	)
+
+	BeforeEach(func() {
+		logs = gbytes.NewBuffer()
+		logger := lager.NewLogger("test")
+		logger.RegisterSink(lager.NewWriterSink(logs, lager.DEBUG))
+		detector = cf_debug_server.NewGoroutineLeakDetector(logger, cf_debug_server.GoroutineLeakDetectorConfig{
+			Interval:  50 * time.Millisecond,
+			Intervals: 3,
+		})
+
+		sink := lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.INFO)
+		handler = cf_debug_server.Handler(sink, cf_debug_server.WithGoroutineLeakDetector(detector))
+
+		release = make(chan struct{})
+		DeferCleanup(func() { close(release) })
+	})
+
+	AfterEach(func() {
+		if process != nil {
+			ginkgomon.Interrupt(process)
+		}
+	})
+
+	type leaks struct {
+		Suspects []struct {
+			Counts []int `json:"counts"`
+			Frames []struct {
+				Function string `json:"function"`
+			} `json:"frames"`
+		} `json:"suspects"`
+	}
+
+	suspects := func() []string {
+		rec := serveRequest(handler, http.MethodGet, "/debug/goroutines/leaks", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		var result leaks
+		Expect(json.Unmarshal(rec.Body.Bytes(), &result)).To(Succeed())
+		var functions []string
+		for _, suspect := range result.Suspects {
+			Expect(suspect.Counts).To(HaveLen(4))
+			functions = append(functions, suspect.Frames[0].Function)
+		}
+		return functions
+	}
+
+	It("flags stacks that keep growing", func() {
+		process = ginkgomon.Invoke(detector)
+
+		stop := make(chan struct{})
+		go leakGoroutines(release, stop)
+
+		Eventually(suspects).Should(ContainElement("code.cloudfoundry.org/debugserver_test.parkOnChannel"))
+		Eventually(logs).Should(gbytes.Say(`goroutine-leak-suspected.*debugserver_test.parkOnChannel`))
+
+		close(stop)
+		Eventually(suspects).ShouldNot(ContainElement("code.cloudfoundry.org/debugserver_test.parkOnChannel"))
+	})
+
+	It("does not flag stacks with a stable count", func() {
+		for i := 0; i < 10; i++ {
+			go parkOnChannel(release)
+		}
+		process = ginkgomon.Invoke(detector)
+
+		Consistently(suspects, 300*time.Millisecond).ShouldNot(ContainElement("code.cloudfoundry.org/debugserver_test.parkOnChannel"))
+	})
+
+	It("responds with service unavailable until it has run", func() {
+		Expect(serveRequest(handler, http.MethodGet, "/debug/goroutines/leaks", "").Code).To(Equal(http.StatusServiceUnavailable))
+	})
+})
@@ -0,0 +1,276 @@
+package debugserver
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	lager "code.cloudfoundry.org/lager/v3"
+)
+
+const maxGoroutineSnapshots = 16
+
+var goroutineSnapshotNameRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// goroutineStack counts the goroutines that share one stack and creator.
+type goroutineStack struct {
+	Count     int              `json:"count"`
+	Frames    []goroutineFrame `json:"frames"`
+	CreatedBy *goroutineFrame  `json:"created_by,omitempty"`
+}
+
+// countGoroutineStacks counts goroutines by stackKey.
+func countGoroutineStacks(goroutines []*goroutineInfo) map[string]*goroutineStack {
+	stacks := map[string]*goroutineStack{}
+	for _, g := range goroutines {
+		key := g.stackKey()
+		stack, ok := stacks[key]
+		if !ok {
+			stack = &goroutineStack{Frames: g.Frames, CreatedBy: g.CreatedBy}
+			stacks[key] = stack
+		}
+		stack.Count++
+	}
+	return stacks
+}
+
+// goroutineSnapshot is the goroutine count per stack at the time it was taken.
+type goroutineSnapshot struct {
+	Name    string    `json:"name"`
+	TakenAt time.Time `json:"taken_at"`
+	Total   int       `json:"total"`
+
+	stacks map[string]*goroutineStack
+}
+
+// goroutineStackDiff is a stack whose goroutine count grew since a snapshot.
+type goroutineStackDiff struct {
+	Before    int              `json:"before"`
+	After     int              `json:"after"`
+	Delta     int              `json:"delta"`
+	Frames    []goroutineFrame `json:"frames"`
+	CreatedBy *goroutineFrame  `json:"created_by,omitempty"`
+}
+
+// goroutineSnapshots holds named snapshots taken through the debug server, so that
+// the goroutines started in between can be found by diffing against them later.
+type goroutineSnapshots struct {
+	mu        sync.Mutex
+	snapshots map[string]*goroutineSnapshot
+}
+
+func newGoroutineSnapshots() *goroutineSnapshots {
+	return &goroutineSnapshots{snapshots: map[string]*goroutineSnapshot{}}
+}
+
+// snapshotsHandler serves /debug/goroutines/snapshots/NAME. POST or PUT takes the named
+// snapshot, replacing an older one of the same name, DELETE removes it and GET shows it.
+// Without a name, GET lists the snapshots.
+func (s *goroutineSnapshots) snapshotsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/debug/goroutines/snapshots"), "/")
+		if name == "" {
+			if r.Method != http.MethodGet {
+				http.Error(w, methodNotAllowedMsg, http.StatusMethodNotAllowed)
+				return
+			}
+			writeJSON(w, http.StatusOK, s.list())
+			return
+		}
+		if !goroutineSnapshotNameRegexp.MatchString(name) {
+			http.Error(w, "invalid snapshot name "+strconv.Quote(name)+", use up to 64 letters, digits, '.', '_' or '-'", http.StatusBadRequest)
+			return
+		}
+		addAuditParams(r, lager.Data{"snapshot": name})
+
+		switch r.Method {
+		case http.MethodGet:
+			snapshot, ok := s.get(name)
+			if !ok {
+				http.Error(w, "no snapshot named "+strconv.Quote(name), http.StatusNotFound)
+				return
+			}
+			writeJSON(w, http.StatusOK, struct {
+				*goroutineSnapshot
+				Stacks []*goroutineStack `json:"stacks"`
+			}{snapshot, sortedGoroutineStacks(snapshot.stacks)})
+		case http.MethodPost, http.MethodPut:
+			snapshot, err := s.take(name)
+			if err != nil {
+				status := http.StatusInternalServerError
+				if err == errTooManyGoroutineSnapshots {
+					status = http.StatusConflict
+				}
+				http.Error(w, err.Error(), status)
+				return
+			}
+			writeJSON(w, http.StatusOK, snapshot)
+		case http.MethodDelete:
+			if !s.remove(name) {
+				http.Error(w, "no snapshot named "+strconv.Quote(name), http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, methodNotAllowedMsg, http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// diffHandler serves /debug/goroutines/diff?snapshot=NAME, the stacks that have more
+// goroutines now than in the snapshot, largest growth first.
+func (s *goroutineSnapshots) diffHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("snapshot")
+		if name == "" {
+			http.Error(w, "missing snapshot parameter", http.StatusBadRequest)
+			return
+		}
+		asJSON, err := wantsJSONFormat(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		snapshot, ok := s.get(name)
+		if !ok {
+			http.Error(w, "no snapshot named "+strconv.Quote(name), http.StatusNotFound)
+			return
+		}
+		goroutines, err := dumpGoroutines()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		grown := diffGoroutineStacks(snapshot.stacks, countGoroutineStacks(goroutines))
+
+		if asJSON {
+			writeJSON(w, http.StatusOK, struct {
+				Snapshot string                `json:"snapshot"`
+				TakenAt  time.Time             `json:"taken_at"`
+				Before   int                   `json:"before"`
+				After    int                   `json:"after"`
+				Grown    []*goroutineStackDiff `json:"grown"`
+			}{snapshot.Name, snapshot.TakenAt, snapshot.Total, len(goroutines), grown})
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		// #nosec G104 - ignore errors writing http response to avoid spamming logs during DoS
+		writeGoroutineDiff(w, snapshot, len(goroutines), grown)
+	}
+}
+
+var errTooManyGoroutineSnapshots = fmt.Errorf("at most %d goroutine snapshots can be kept, delete one first", maxGoroutineSnapshots)
+
+func (s *goroutineSnapshots) take(name string) (*goroutineSnapshot, error) {
+	s.mu.Lock()
+	_, exists := s.snapshots[name]
+	full := len(s.snapshots) >= maxGoroutineSnapshots
+	s.mu.Unlock()
+	if !exists && full {
+		return nil, errTooManyGoroutineSnapshots
+	}
+
+	goroutines, err := dumpGoroutines()
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &goroutineSnapshot{
+		Name:    name,
+		TakenAt: time.Now().UTC(),
+		Total:   len(goroutines),
+		stacks:  countGoroutineStacks(goroutines),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.snapshots[name]; !exists && len(s.snapshots) >= maxGoroutineSnapshots {
+		return nil, errTooManyGoroutineSnapshots
+	}
+	s.snapshots[name] = snapshot
+	return snapshot, nil
+}
+
+func (s *goroutineSnapshots) get(name string) (*goroutineSnapshot, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot, ok := s.snapshots[name]
+	return snapshot, ok
+}
+
+func (s *goroutineSnapshots) remove(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.snapshots[name]
+	delete(s.snapshots, name)
+	return ok
+}
+
+// list returns the snapshots oldest first.
+func (s *goroutineSnapshots) list() []*goroutineSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := make([]*goroutineSnapshot, 0, len(s.snapshots))
+	for _, snapshot := range s.snapshots {
+		list = append(list, snapshot)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].TakenAt.Before(list[j].TakenAt)
+	})
+	return list
+}
+
+// diffGoroutineStacks returns the stacks with more goroutines in after than in before.
+func diffGoroutineStacks(before, after map[string]*goroutineStack) []*goroutineStackDiff {
+	grown := []*goroutineStackDiff{}
+	for key, stack := range after {
+		var count int
+		if old, ok := before[key]; ok {
+			count = old.Count
+		}
+		if stack.Count > count {
+			grown = append(grown, &goroutineStackDiff{
+				Before:    count,
+				After:     stack.Count,
+				Delta:     stack.Count - count,
+				Frames:    stack.Frames,
+				CreatedBy: stack.CreatedBy,
+			})
+		}
+	}
+	sort.Slice(grown, func(i, j int) bool {
+		if grown[i].Delta != grown[j].Delta {
+			return grown[i].Delta > grown[j].Delta
+		}
+		return grown[i].After > grown[j].After
+	})
+	return grown
+}
+
+func sortedGoroutineStacks(stacks map[string]*goroutineStack) []*goroutineStack {
+	sorted := make([]*goroutineStack, 0, len(stacks))
+	for _, stack := range stacks {
+		sorted = append(sorted, stack)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Count > sorted[j].Count
+	})
+	return sorted
+}
+
+func writeGoroutineDiff(w io.Writer, snapshot *goroutineSnapshot, after int, grown []*goroutineStackDiff) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%d -> %d goroutines since snapshot %q taken at %s, %d stacks grew\n",
+		snapshot.Total, after, snapshot.Name, snapshot.TakenAt.Format(time.RFC3339), len(grown))
+	for _, diff := range grown {
+		fmt.Fprintf(bw, "\n+%d goroutines (%d -> %d):\n", diff.Delta, diff.Before, diff.After)
+		writeGoroutineStack(bw, diff.Frames, diff.CreatedBy)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,124 @@
+package debugserver_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	cf_debug_server "code.cloudfoundry.org/debugserver"
+	lager "code.cloudfoundry.org/lager/v3"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type goroutineDiff struct {
+	Snapshot string `json:"snapshot"`
+	Before   int    `json:"before"`
+	After    int    `json:"after"`
+	Grown    []struct {
+		Before int `json:"before"`
+		After  int `json:"after"`
+		Delta  int `json:"delta"`
+		Frames []struct {
+			Function string `json:"function"`
+		} `json:"frames"`
+	} `json:"grown"`
+}
+
+var _ = Describe("Goroutine snapshots", func() {
+	var (
+		handler http.Handler
+		release chan struct{}
+	)
+
+	BeforeEach(func() {
+		sink := lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.INFO)
+		handler = cf_debug_server.Handler(sink)
+		release = make(chan struct{})
+		DeferCleanup(func() { close(release) })
+	})
+
+	diff := func(name string) goroutineDiff {
+		rec := serveRequest(handler, http.MethodGet, "/debug/goroutines/diff?format=json&snapshot="+name, "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		var result goroutineDiff
+		Expect(json.Unmarshal(rec.Body.Bytes(), &result)).To(Succeed())
+		return result
+	}
+
+	It("reports the stacks that grew since a snapshot", func() {
+		Expect(serveRequest(handler, http.MethodPost, "/debug/goroutines/snapshots/before", "").Code).To(Equal(http.StatusOK))
+		for i := 0; i < 7; i++ {
+			go parkOnChannel(release)
+		}
+
+		Eventually(func() []int {
+			var deltas []int
+			for _, grown := range diff("before").Grown {
+				if grown.Frames[0].Function == "code.cloudfoundry.org/debugserver_test.parkOnChannel" {
+					deltas = append(deltas, grown.Delta)
+				}
+			}
+			return deltas
+		}).Should(Equal([]int{7}))
+
+		result := diff("before")
+		Expect(result.Snapshot).To(Equal("before"))
+		Expect(result.Before).To(BeNumerically(">", 0))
+		Expect(result.After).To(BeNumerically(">", 0))
+	})
+
+	It("renders the diff as text by default", func() {
+		Expect(serveRequest(handler, http.MethodPut, "/debug/goroutines/snapshots/before", "").Code).To(Equal(http.StatusOK))
+		for i := 0; i < 3; i++ {
+			go parkOnChannel(release)
+		}
+
+		Eventually(func() string {
+			return serveRequest(handler, http.MethodGet, "/debug/goroutines/diff?snapshot=before", "").Body.String()
+		}).Should(ContainSubstring("+3 goroutines (0 -> 3):\n    code.cloudfoundry.org/debugserver_test.parkOnChannel\n"))
+	})
+
+	It("lists, shows and deletes snapshots", func() {
+		Expect(serveRequest(handler, http.MethodPost, "/debug/goroutines/snapshots/one", "").Code).To(Equal(http.StatusOK))
+		Expect(serveRequest(handler, http.MethodPost, "/debug/goroutines/snapshots/two", "").Code).To(Equal(http.StatusOK))
+
+		var list []struct {
+			Name  string `json:"name"`
+			Total int    `json:"total"`
+		}
+		Expect(json.Unmarshal(serveRequest(handler, http.MethodGet, "/debug/goroutines/snapshots", "").Body.Bytes(), &list)).To(Succeed())
+		Expect(list).To(HaveLen(2))
+		Expect(list[0].Name).To(Equal("one"))
+		Expect(list[1].Name).To(Equal("two"))
+		Expect(list[0].Total).To(BeNumerically(">", 0))
+
+		rec := serveRequest(handler, http.MethodGet, "/debug/goroutines/snapshots/one", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(ContainSubstring(`"stacks":[{"count":`))
+
+		Expect(serveRequest(handler, http.MethodDelete, "/debug/goroutines/snapshots/one", "").Code).To(Equal(http.StatusNoContent))
+		Expect(serveRequest(handler, http.MethodGet, "/debug/goroutines/snapshots/one", "").Code).To(Equal(http.StatusNotFound))
+		Expect(serveRequest(handler, http.MethodDelete, "/debug/goroutines/snapshots/one", "").Code).To(Equal(http.StatusNotFound))
+		Expect(serveRequest(handler, http.MethodGet, "/debug/goroutines/diff?snapshot=one", "").Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("limits the number of snapshots", func() {
+		for i := 0; i < 16; i++ {
+			Expect(serveRequest(handler, http.MethodPost, fmt.Sprintf("/debug/goroutines/snapshots/s%d", i), "").Code).To(Equal(http.StatusOK))
+		}
+		Expect(serveRequest(handler, http.MethodPost, "/debug/goroutines/snapshots/one-more", "").Code).To(Equal(http.StatusConflict))
+		Expect(serveRequest(handler, http.MethodPost, "/debug/goroutines/snapshots/s0", "").Code).To(Equal(http.StatusOK))
+	})
+
+	DescribeTable("rejects invalid requests",
+		func(method, target string, status int) {
+			Expect(serveRequest(handler, method, target, "").Code).To(Equal(status))
+		},
+		Entry("invalid name", http.MethodPost, "/debug/goroutines/snapshots/a%20b", http.StatusBadRequest),
+		Entry("missing snapshot", http.MethodGet, "/debug/goroutines/diff", http.StatusBadRequest),
+		Entry("wrong method", http.MethodPatch, "/debug/goroutines/snapshots/one", http.StatusMethodNotAllowed),
+	)
+})
@@ -0,0 +1,317 @@
+package debugserver
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var goroutineHeaderRegexp = regexp.MustCompile(`^goroutine (\d+)(?: [^\[]*)? \[(.*)\]:$`)
+
+// goroutineFrame is one call in a goroutine stack.
+type goroutineFrame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// goroutineInfo is one goroutine parsed from a debug=2 goroutine dump.
+type goroutineInfo struct {
+	ID             int
+	State          string
+	WaitMinutes    int
+	LockedToThread bool
+	Frames         []goroutineFrame
+	CreatedBy      *goroutineFrame
+}
+
+// goroutineGroup collects goroutines with the same state and identical stacks.
+type goroutineGroup struct {
+	Count          int              `json:"count"`
+	State          string           `json:"state"`
+	MinWaitMinutes int              `json:"min_wait_minutes"`
+	MaxWaitMinutes int              `json:"max_wait_minutes"`
+	LockedToThread bool             `json:"locked_to_thread,omitempty"`
+	IDs            []int            `json:"ids"`
+	Frames         []goroutineFrame `json:"frames"`
+	CreatedBy      *goroutineFrame  `json:"created_by,omitempty"`
+}
+
+// goroutineFilter selects goroutines from a dump; zero values match everything.
+type goroutineFilter struct {
+	function *regexp.Regexp
+	states   map[string]bool
+	minWait  time.Duration
+}
+
+// goroutinesHandler serves the goroutine dump with identical stacks grouped together,
+// largest group first. Query parameters:
+//
+//	func=REGEX     only goroutines with a frame, or creator, whose function matches
+//	state=STATE    only goroutines in this wait state; may be repeated
+//	min_wait=DUR   only goroutines that have been waiting at least this long
+//	format=json|text
+//
+// The runtime reports wait times in whole minutes, and only for goroutines blocked for at
+// least a minute, so min_wait is rounded down to minutes.
+func goroutinesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		filter, err := parseGoroutineFilter(query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		asJSON, err := wantsJSONFormat(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		goroutines, err := dumpGoroutines()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var matched []*goroutineInfo
+		for _, g := range goroutines {
+			if filter.matches(g) {
+				matched = append(matched, g)
+			}
+		}
+		groups := groupGoroutines(matched)
+
+		if asJSON {
+			writeJSON(w, http.StatusOK, struct {
+				Total   int               `json:"total"`
+				Matched int               `json:"matched"`
+				Groups  []*goroutineGroup `json:"groups"`
+			}{len(goroutines), len(matched), groups})
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		// #nosec G104 - ignore errors writing http response to avoid spamming logs during DoS
+		writeGoroutineGroups(w, len(goroutines), len(matched), groups)
+	}
+}
+
+func parseGoroutineFilter(query url.Values) (goroutineFilter, error) {
+	var filter goroutineFilter
+	if expr := query.Get("func"); expr != "" {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return filter, fmt.Errorf("invalid func: %w", err)
+		}
+		filter.function = re
+	}
+	for _, value := range query["state"] {
+		for _, state := range strings.Split(value, ",") {
+			if state = strings.TrimSpace(state); state != "" {
+				if filter.states == nil {
+					filter.states = map[string]bool{}
+				}
+				filter.states[strings.ToLower(state)] = true
+			}
+		}
+	}
+	if value := query.Get("min_wait"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return filter, fmt.Errorf("invalid min_wait: %w", err)
+		}
+		filter.minWait = d
+	}
+	return filter, nil
+}
+
+func (f goroutineFilter) matches(g *goroutineInfo) bool {
+	if f.states != nil && !f.states[strings.ToLower(g.State)] {
+		return false
+	}
+	if time.Duration(g.WaitMinutes)*time.Minute < f.minWait.Truncate(time.Minute) {
+		return false
+	}
+	if f.function == nil {
+		return true
+	}
+	for _, frame := range g.Frames {
+		if f.function.MatchString(frame.Function) {
+			return true
+		}
+	}
+	return g.CreatedBy != nil && f.function.MatchString(g.CreatedBy.Function)
+}
+
+// dumpGoroutines parses the stacks of all current goroutines.
+func dumpGoroutines() ([]*goroutineInfo, error) {
+	var dump bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&dump, 2); err != nil {
+		return nil, fmt.Errorf("failed to dump goroutines: %w", err)
+	}
+	goroutines, err := parseGoroutineDump(&dump)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse goroutine dump: %w", err)
+	}
+	return goroutines, nil
+}
+
+// parseGoroutineDump parses the output of the goroutine profile with debug=2,
+// which is the same format the runtime uses for tracebacks.
+func parseGoroutineDump(r io.Reader) ([]*goroutineInfo, error) {
+	var (
+		goroutines []*goroutineInfo
+		current    *goroutineInfo
+		frame      *goroutineFrame
+	)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			current, frame = nil, nil
+		case current == nil:
+			m := goroutineHeaderRegexp.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("unexpected line %q", line)
+			}
+			id, _ := strconv.Atoi(m[1])
+			current = &goroutineInfo{ID: id}
+			parseGoroutineState(current, m[2])
+			goroutines = append(goroutines, current)
+		case strings.HasPrefix(line, "\t"):
+			if frame == nil {
+				continue
+			}
+			location := strings.TrimSpace(line)
+			if i := strings.LastIndex(location, " +0x"); i >= 0 {
+				location = location[:i]
+			}
+			if i := strings.LastIndex(location, ":"); i >= 0 {
+				frame.Line, _ = strconv.Atoi(location[i+1:])
+				location = location[:i]
+			}
+			frame.File = location
+			frame = nil
+		case strings.HasPrefix(line, "created by "):
+			function := strings.TrimPrefix(line, "created by ")
+			if i := strings.Index(function, " in goroutine "); i >= 0 {
+				function = function[:i]
+			}
+			current.CreatedBy = &goroutineFrame{Function: function}
+			frame = current.CreatedBy
+		case strings.HasPrefix(line, "..."):
+			// "...additional frames elided..." for very deep stacks.
+			frame = nil
+		default:
+			function := line
+			if i := strings.LastIndex(function, "("); i > 0 {
+				function = function[:i]
+			}
+			current.Frames = append(current.Frames, goroutineFrame{Function: function})
+			frame = &current.Frames[len(current.Frames)-1]
+		}
+	}
+	return goroutines, scanner.Err()
+}
+
+// parseGoroutineState parses the bracketed part of a goroutine header,
+// for example "chan receive, 12 minutes, locked to thread".
+func parseGoroutineState(g *goroutineInfo, header string) {
+	parts := strings.Split(header, ", ")
+	g.State = parts[0]
+	for _, part := range parts[1:] {
+		switch {
+		case part == "locked to thread":
+			g.LockedToThread = true
+		case strings.HasSuffix(part, " minutes"):
+			g.WaitMinutes, _ = strconv.Atoi(strings.TrimSuffix(part, " minutes"))
+		}
+	}
+}
+
+// stackKey identifies the stack and creator of a goroutine, regardless of its state.
+func (g *goroutineInfo) stackKey() string {
+	var key strings.Builder
+	for _, frame := range g.Frames {
+		fmt.Fprintf(&key, "%s %s:%d\n", frame.Function, frame.File, frame.Line)
+	}
+	if g.CreatedBy != nil {
+		fmt.Fprintf(&key, "created by %s %s:%d\n", g.CreatedBy.Function, g.CreatedBy.File, g.CreatedBy.Line)
+	}
+	return key.String()
+}
+
+// groupGoroutines groups goroutines by state, stack and creator, largest group first.
+func groupGoroutines(goroutines []*goroutineInfo) []*goroutineGroup {
+	index := map[string]*goroutineGroup{}
+	groups := []*goroutineGroup{}
+	for _, g := range goroutines {
+		key := g.State + "\n" + g.stackKey()
+		if g.LockedToThread {
+			key += "\nlocked"
+		}
+
+		group, ok := index[key]
+		if !ok {
+			group = &goroutineGroup{
+				State:          g.State,
+				MinWaitMinutes: g.WaitMinutes,
+				LockedToThread: g.LockedToThread,
+				Frames:         g.Frames,
+				CreatedBy:      g.CreatedBy,
+			}
+			index[key] = group
+			groups = append(groups, group)
+		}
+		group.Count++
+		group.IDs = append(group.IDs, g.ID)
+		group.MinWaitMinutes = min(group.MinWaitMinutes, g.WaitMinutes)
+		group.MaxWaitMinutes = max(group.MaxWaitMinutes, g.WaitMinutes)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Count > groups[j].Count
+	})
+	return groups
+}
+
+func writeGoroutineGroups(w io.Writer, total, matched int, groups []*goroutineGroup) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%d of %d goroutines in %d groups\n", matched, total, len(groups))
+	for _, group := range groups {
+		state := group.State
+		if group.MaxWaitMinutes > 0 {
+			if group.MinWaitMinutes == group.MaxWaitMinutes {
+				state += fmt.Sprintf(", %d minutes", group.MaxWaitMinutes)
+			} else {
+				state += fmt.Sprintf(", %d-%d minutes", group.MinWaitMinutes, group.MaxWaitMinutes)
+			}
+		}
+		if group.LockedToThread {
+			state += ", locked to thread"
+		}
+		fmt.Fprintf(bw, "\n%d goroutines [%s]:\n", group.Count, state)
+		writeGoroutineStack(bw, group.Frames, group.CreatedBy)
+	}
+	return bw.Flush()
+}
+
+func writeGoroutineStack(w io.Writer, frames []goroutineFrame, createdBy *goroutineFrame) {
+	for _, frame := range frames {
+		fmt.Fprintf(w, "    %s\n        %s:%d\n", frame.Function, frame.File, frame.Line)
+	}
+	if createdBy != nil {
+		fmt.Fprintf(w, "    created by %s\n        %s:%d\n", createdBy.Function, createdBy.File, createdBy.Line)
+	}
+}
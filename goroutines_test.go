@@ -0,0 +1,132 @@
+package debugserver_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+
+	cf_debug_server "code.cloudfoundry.org/debugserver"
+	lager "code.cloudfoundry.org/lager/v3"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func parkOnChannel(ch chan struct{}) {
+	<-ch
+}
+
+func parkOnMutex(mu *sync.Mutex) {
+	mu.Lock()
+	mu.Unlock()
+}
+
+type goroutineDump struct {
+	Total   int `json:"total"`
+	Matched int `json:"matched"`
+	Groups  []struct {
+		Count  int    `json:"count"`
+		State  string `json:"state"`
+		IDs    []int  `json:"ids"`
+		Frames []struct {
+			Function string `json:"function"`
+			File     string `json:"file"`
+			Line     int    `json:"line"`
+		} `json:"frames"`
+		CreatedBy *struct {
+			Function string `json:"function"`
+		} `json:"created_by"`
+	} `json:"groups"`
+}
+
+var _ = Describe("/debug/goroutines", func() {
+	var (
+		handler http.Handler
+		release chan struct{}
+		mu      *sync.Mutex
+	)
+
+	BeforeEach(func() {
+		sink := lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.INFO)
+		handler = cf_debug_server.Handler(sink)
+
+		release = make(chan struct{})
+		mu = &sync.Mutex{}
+		mu.Lock()
+		for i := 0; i < 5; i++ {
+			go parkOnChannel(release)
+		}
+		go parkOnMutex(mu)
+		DeferCleanup(func() {
+			close(release)
+			mu.Unlock()
+		})
+	})
+
+	dump := func(target string) goroutineDump {
+		rec := serveRequest(handler, http.MethodGet, target, "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Header().Get("Content-Type")).To(Equal("application/json"))
+		var result goroutineDump
+		Expect(json.Unmarshal(rec.Body.Bytes(), &result)).To(Succeed())
+		return result
+	}
+
+	It("groups identical stacks with their count and state", func() {
+		Eventually(func() goroutineDump {
+			return dump("/debug/goroutines?format=json&func=parkOnChannel")
+		}).Should(WithTransform(func(d goroutineDump) int { return d.Matched }, Equal(5)))
+
+		result := dump("/debug/goroutines?format=json&func=parkOnChannel")
+		Expect(result.Total).To(BeNumerically(">", 6))
+		Expect(result.Groups).To(HaveLen(1))
+		group := result.Groups[0]
+		Expect(group.Count).To(Equal(5))
+		Expect(group.State).To(Equal("chan receive"))
+		Expect(group.IDs).To(HaveLen(5))
+		Expect(group.Frames[0].Function).To(Equal("code.cloudfoundry.org/debugserver_test.parkOnChannel"))
+		Expect(group.Frames[0].File).To(HaveSuffix("goroutines_test.go"))
+		Expect(group.Frames[0].Line).To(BeNumerically(">", 0))
+		Expect(group.CreatedBy.Function).To(ContainSubstring("debugserver_test"))
+	})
+
+	It("filters by state", func() {
+		Eventually(func() int {
+			return dump("/debug/goroutines?format=json&state=sync.Mutex.Lock&func=debugserver_test").Matched
+		}).Should(Equal(1))
+
+		Expect(dump("/debug/goroutines?format=json&state=running&func=parkOnChannel").Matched).To(BeZero())
+	})
+
+	It("filters by wait time", func() {
+		Expect(dump("/debug/goroutines?format=json&func=parkOnChannel&min_wait=1m").Matched).To(BeZero())
+		Eventually(func() int {
+			return dump("/debug/goroutines?format=json&func=parkOnChannel&min_wait=30s").Matched
+		}).Should(Equal(5))
+	})
+
+	It("renders the groups as text by default", func() {
+		Eventually(func() string {
+			return serveRequest(handler, http.MethodGet, "/debug/goroutines?func=parkOnChannel", "").Body.String()
+		}).Should(ContainSubstring("5 goroutines [chan receive]:\n    code.cloudfoundry.org/debugserver_test.parkOnChannel\n"))
+
+		rec := serveRequest(handler, http.MethodGet, "/debug/goroutines", "")
+		Expect(rec.Header().Get("Content-Type")).To(Equal("text/plain; charset=utf-8"))
+		Expect(rec.Body.String()).To(MatchRegexp(`^\d+ of \d+ goroutines in \d+ groups\n`))
+	})
+
+	It("returns JSON when the client accepts it", func() {
+		rec := serveRequest(handler, http.MethodGet, "/debug/goroutines", "", "Accept", "application/json")
+		Expect(rec.Header().Get("Content-Type")).To(Equal("application/json"))
+	})
+
+	DescribeTable("rejects invalid parameters",
+		func(target string) {
+			Expect(serveRequest(handler, http.MethodGet, target, "").Code).To(Equal(http.StatusBadRequest))
+		},
+		Entry("func", "/debug/goroutines?func=("),
+		Entry("min_wait", "/debug/goroutines?min_wait=soon"),
+		Entry("format", "/debug/goroutines?format=xml"),
+	)
+})
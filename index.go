@@ -0,0 +1,56 @@
+package debugserver
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+)
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head><title>debug server</title></head>
+<body>
+<table>
+<tr><th>Endpoint</th><th>Kind</th><th>Description</th></tr>
+{{range .}}<tr><td><a href="{{.Path}}">{{.Path}}</a></td><td>{{.Kind}}</td><td>{{.Description}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// indexHandler lists the endpoints of the server as text, JSON or, for browsers, HTML.
+func indexHandler(s *Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		asJSON, err := wantsJSONFormat(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		endpoints := s.Endpoints()
+		for i := range endpoints {
+			endpoints[i].Path = strings.TrimSuffix(endpoints[i].Path, "{$}")
+		}
+
+		switch {
+		case asJSON:
+			writeJSON(w, http.StatusOK, struct {
+				Endpoints []Endpoint `json:"endpoints"`
+			}{endpoints})
+		case r.URL.Query().Get("format") == "" && strings.Contains(r.Header.Get("Accept"), "text/html"):
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			// #nosec G104 - ignore errors writing http response to avoid spamming logs during DoS
+			indexTemplate.Execute(w, endpoints)
+		default:
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+			for _, endpoint := range endpoints {
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", endpoint.Path, endpoint.Kind, endpoint.Description)
+			}
+			// #nosec G104 - ignore errors writing http response to avoid spamming logs during DoS
+			tw.Flush()
+		}
+	}
+}
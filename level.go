@@ -0,0 +1,141 @@
+package debugserver
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Level is a log level as the debug server sees it. It uses the scale of log/slog, so
+// that levels between the named ones can be told apart, and controllers translate it to
+// the levels of their logging library.
+type Level int
+
+const (
+	LevelDebug Level = -4
+	LevelInfo  Level = 0
+	LevelWarn  Level = 4
+	LevelError Level = 8
+	LevelFatal Level = 12
+)
+
+// String returns the name of the level, such as "warn". Levels between the named ones
+// are written as an offset from the named level below them, such as "info+2", and
+// levels below debug as an offset from debug, such as "debug-4".
+func (l Level) String() string {
+	name := func(base string, offset Level) string {
+		if offset == 0 {
+			return base
+		}
+		return fmt.Sprintf("%s%+d", base, offset)
+	}
+	switch {
+	case l < LevelInfo:
+		return name("debug", l-LevelDebug)
+	case l < LevelWarn:
+		return name("info", l-LevelInfo)
+	case l < LevelError:
+		return name("warn", l-LevelWarn)
+	case l < LevelFatal:
+		return name("error", l-LevelError)
+	default:
+		return name("fatal", l-LevelFatal)
+	}
+}
+
+// MarshalText writes the level as String does.
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText reads a level as ParseLevel does.
+func (l *Level) UnmarshalText(text []byte) error {
+	level, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = level
+	return nil
+}
+
+// ParseLevel reads the levels accepted by /log-level: a name in any case, its first
+// letter, or the numbers 0 to 4 for debug to fatal. A name may be followed by an offset,
+// such as "info+2" or slog's "DEBUG-4", as written by Level.String. A level on the slog
+// scale is given with a "slog:" prefix, such as "slog:-8" for debug-4, so that it cannot
+// be mistaken for one of the numbers 0 to 4. Levels more than 12 beyond debug or fatal
+// are refused.
+func ParseLevel(s string) (Level, error) {
+	input := strings.ToLower(strings.TrimSpace(s))
+	if normalized := normalizeLogLevel(input); normalized != "" {
+		return levelNames[normalized], nil
+	}
+	level, ok := parseLevelValue(input)
+	if !ok {
+		return 0, errors.New("invalid log level: " + s)
+	}
+	if level < minLevel || level > maxLevel {
+		return 0, fmt.Errorf("log level %s is out of range, use %s to %s", s, minLevel, maxLevel)
+	}
+	return level, nil
+}
+
+// The range of levels ParseLevel accepts, which leaves room for the custom levels of
+// logging libraries, such as zap's panic level at error+2.
+const (
+	minLevel = LevelDebug - 12
+	maxLevel = LevelFatal + 12
+)
+
+// parseLevelValue reads a level on the slog scale, such as "slog:-8", or a name with an
+// offset, such as "info+2".
+func parseLevelValue(input string) (Level, bool) {
+	if value, ok := strings.CutPrefix(input, "slog:"); ok {
+		n, err := strconv.Atoi(value)
+		return Level(n), err == nil
+	}
+	if i := strings.IndexAny(input, "+-"); i > 0 {
+		base, ok := levelNames[input[:i]]
+		offset, err := strconv.Atoi(input[i:])
+		if ok && err == nil {
+			return base + Level(offset), true
+		}
+	}
+	return 0, false
+}
+
+var levelNames = map[string]Level{
+	"debug": LevelDebug,
+	"info":  LevelInfo,
+	"warn":  LevelWarn,
+	"error": LevelError,
+	"fatal": LevelFatal,
+}
+
+// LevelController sets the level of a logger. NewLagerLevelController,
+// NewSlogLevelController and NewZapLevelController adapt the common logging libraries.
+type LevelController interface {
+	SetLevel(level Level)
+}
+
+// LevelGetter is implemented by level controllers that can report their current level,
+// which GET /log-level and timed overrides need.
+type LevelGetter interface {
+	GetLevel() Level
+}
+
+// WithLevelController serves /log-level for a level controller, like WithLogController
+// does for a lager sink.
+func WithLevelController(ctrl LevelController) Option {
+	return func(o *options) {
+		o.logController = ctrl
+	}
+}
+
+// WithNamedLevelController serves /log-level/NAME for a level controller, like
+// WithNamedLogController does for a lager sink.
+func WithNamedLevelController(name string, ctrl LevelController) Option {
+	return func(o *options) {
+		o.namedLogControllers = append(o.namedLogControllers, namedLogController{name: name, ctrl: ctrl})
+	}
+}
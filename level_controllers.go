@@ -0,0 +1,171 @@
+package debugserver
+
+import (
+	"sync"
+
+	lager "code.cloudfoundry.org/lager/v3"
+)
+
+// warnSentinelLevel is what older versions of the debug server sent to SetMinLevel for
+// warn, relying on the sink to treat an unknown level as "log nothing below it". It is
+// still sent to log controllers other than a lager.ReconfigurableSink, also when they are
+// wrapped in a LagerAdapter, which may depend on it, and read back as warn from any of them.
+const warnSentinelLevel = lager.LogLevel(99)
+
+// NewLagerLevelController controls the level of a lager sink. lager has no warn level
+// and logs warnings at info, so warn keeps the sink at info to not lose them. The
+// controller reports the level it set, such as warn, for as long as the sink stays at
+// the lager level it was sent as. It implements LevelGetter when the sink has
+// GetMinLevel, as lager.ReconfigurableSink does.
+func NewLagerLevelController(sink ReconfigurableSinkInterface) LevelController {
+	return newLagerLevelController(sink, lager.INFO)
+}
+
+// levelControllerFor adapts the log controllers taken by WithLogController.
+func levelControllerFor(zapCtrl zapLogLevelController) LevelController {
+	switch ctrl := zapCtrl.(type) {
+	case nil:
+		return nil
+	case LevelController:
+		return ctrl
+	case *LagerAdapter:
+		return levelControllerFor(ctrl.Sink)
+	case *lager.ReconfigurableSink:
+		return NewLagerLevelController(ctrl)
+	default:
+		return newLagerLevelController(zapCtrl, warnSentinelLevel)
+	}
+}
+
+func newLagerLevelController(sink zapLogLevelController, warn lager.LogLevel) LevelController {
+	ctrl := &lagerLevelController{sink: sink, warn: warn}
+	if getter, ok := minLevelGetter(sink); ok {
+		return &readableLagerLevelController{lagerLevelController: ctrl, getter: getter}
+	}
+	return ctrl
+}
+
+type lagerLevelController struct {
+	sink zapLogLevelController
+	warn lager.LogLevel
+
+	mu sync.Mutex
+	// last is the level set last and sent the lager level it was sent as, which tell
+	// warn apart from info once it reached the sink.
+	last    Level
+	sent    lager.LogLevel
+	hasLast bool
+}
+
+func (c *lagerLevelController) SetLevel(level Level) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sent = c.lagerLevel(level)
+	c.last = level
+	c.hasLast = true
+	c.sink.SetMinLevel(c.sent)
+}
+
+// lagerLevel rounds a level down to the nearest lager level.
+func (c *lagerLevelController) lagerLevel(level Level) lager.LogLevel {
+	switch {
+	case level < LevelInfo:
+		return lager.DEBUG
+	case level < LevelWarn:
+		return lager.INFO
+	case level < LevelError:
+		return c.warn
+	case level < LevelFatal:
+		return lager.ERROR
+	default:
+		return lager.FATAL
+	}
+}
+
+type readableLagerLevelController struct {
+	*lagerLevelController
+	getter zapLogLevelGetter
+}
+
+func (c *readableLagerLevelController) GetLevel() Level {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current := c.getter.GetMinLevel()
+	if c.hasLast && current == c.sent {
+		return c.last
+	}
+	return levelFromLager(current)
+}
+
+// levelFromLager reads a level that was set on a lager sink directly. Levels above fatal,
+// which log nothing, are reported as offsets from fatal.
+func levelFromLager(level lager.LogLevel) Level {
+	switch {
+	case level == warnSentinelLevel:
+		return LevelWarn
+	case level <= lager.DEBUG:
+		return LevelDebug + Level(level-lager.DEBUG)
+	case level == lager.INFO:
+		return LevelInfo
+	case level == lager.ERROR:
+		return LevelError
+	default:
+		return LevelFatal + Level(level-lager.FATAL)
+	}
+}
+
+// Levels of go.uber.org/zap/zapcore, which this package does not depend on.
+const (
+	zapDebugLevel = -1
+	zapInfoLevel  = 0
+	zapWarnLevel  = 1
+	zapErrorLevel = 2
+	zapFatalLevel = 5
+)
+
+// NewZapLevelController controls a zap logger through the SetLevel and Level methods of
+// its zap.AtomicLevel:
+//
+//	debugserver.NewZapLevelController(atom.SetLevel, atom.Level)
+//
+// zap's dpanic and panic levels read back as error+1 and error+2.
+func NewZapLevelController[L ~int8](set func(L), get func() L) LevelController {
+	return zapLevelController[L]{set: set, get: get}
+}
+
+type zapLevelController[L ~int8] struct {
+	set func(L)
+	get func() L
+}
+
+func (c zapLevelController[L]) SetLevel(level Level) {
+	switch {
+	case level < LevelInfo:
+		c.set(zapDebugLevel)
+	case level < LevelWarn:
+		c.set(zapInfoLevel)
+	case level < LevelError:
+		c.set(zapWarnLevel)
+	case level < LevelFatal:
+		c.set(zapErrorLevel)
+	default:
+		c.set(zapFatalLevel)
+	}
+}
+
+func (c zapLevelController[L]) GetLevel() Level {
+	switch level := int(c.get()); {
+	case level <= zapDebugLevel:
+		return LevelDebug + Level(level-zapDebugLevel)
+	case level == zapInfoLevel:
+		return LevelInfo
+	case level == zapWarnLevel:
+		return LevelWarn
+	case level < zapFatalLevel:
+		return LevelError + Level(level-zapErrorLevel)
+	default:
+		return LevelFatal + Level(level-zapFatalLevel)
+	}
+}
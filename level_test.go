@@ -0,0 +1,115 @@
+package debugserver_test
+
+import (
+	"io"
+	"net/http"
+
+	cf_debug_server "code.cloudfoundry.org/debugserver"
+	lager "code.cloudfoundry.org/lager/v3"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// zapLevel stands in for zapcore.Level, and atomicZapLevel for zap.AtomicLevel.
+type zapLevel int8
+
+type atomicZapLevel struct {
+	level zapLevel
+}
+
+func (a *atomicZapLevel) SetLevel(level zapLevel) { a.level = level }
+func (a *atomicZapLevel) Level() zapLevel         { return a.level }
+
+var _ = Describe("Levels", func() {
+	DescribeTable("ParseLevel and String round-trip",
+		func(input string, level cf_debug_server.Level, name string) {
+			parsed, err := cf_debug_server.ParseLevel(input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(parsed).To(Equal(level))
+			Expect(parsed.String()).To(Equal(name))
+		},
+		Entry("warn", "WARN", cf_debug_server.LevelWarn, "warn"),
+		Entry("numeric shorthand", "2", cf_debug_server.LevelWarn, "warn"),
+		Entry("offset above a named level", "INFO+2", cf_debug_server.LevelInfo+2, "info+2"),
+		Entry("offset below debug", "debug-4", cf_debug_server.LevelDebug-4, "debug-4"),
+		Entry("offset onto a named level", "info+4", cf_debug_server.LevelWarn, "warn"),
+		Entry("slog number", "slog:4", cf_debug_server.LevelWarn, "warn"),
+		Entry("negative slog number", "SLOG:-8", cf_debug_server.LevelDebug-4, "debug-4"),
+	)
+
+	It("rejects unknown levels", func() {
+		_, err := cf_debug_server.ParseLevel("loud+1")
+		Expect(err).To(MatchError("invalid log level: loud+1"))
+	})
+
+	DescribeTable("rejects numbers that are not a shorthand or a slog level",
+		func(input string) {
+			_, err := cf_debug_server.ParseLevel(input)
+			Expect(err).To(MatchError("invalid log level: " + input))
+		},
+		Entry("slog's warn without a prefix", "8"),
+		Entry("a negative number", "-4"),
+	)
+
+	DescribeTable("rejects levels out of range",
+		func(input string) {
+			_, err := cf_debug_server.ParseLevel(input)
+			Expect(err).To(MatchError("log level " + input + " is out of range, use debug-12 to fatal+12"))
+		},
+		Entry("a large slog number", "slog:100000"),
+		Entry("a large offset", "fatal+13"),
+		Entry("a large negative offset", "debug-13"),
+	)
+
+	Describe("lager", func() {
+		var sink *lager.ReconfigurableSink
+
+		BeforeEach(func() {
+			sink = lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.ERROR)
+		})
+
+		It("keeps the sink at info for warn and reports warn", func() {
+			handler := cf_debug_server.Handler(sink)
+			Expect(serveRequest(handler, http.MethodPost, "/log-level", "warn").Code).To(Equal(http.StatusOK))
+			Expect(sink.GetMinLevel()).To(Equal(lager.INFO))
+			Expect(serveRequest(handler, http.MethodGet, "/log-level", "").Body.String()).To(Equal("warn\n"))
+
+			sink.SetMinLevel(lager.DEBUG)
+			sink.SetMinLevel(lager.INFO)
+			Expect(serveRequest(handler, http.MethodGet, "/log-level", "").Body.String()).To(Equal("warn\n"))
+
+			sink.SetMinLevel(lager.ERROR)
+			Expect(serveRequest(handler, http.MethodGet, "/log-level", "").Body.String()).To(Equal("error\n"))
+		})
+
+		It("reads the level older versions set for warn", func() {
+			sink.SetMinLevel(99)
+			Expect(serveRequest(cf_debug_server.Handler(sink), http.MethodGet, "/log-level", "").Body.String()).To(Equal("warn\n"))
+		})
+
+		It("still sends other log controllers the level they got for warn before", func() {
+			ctrl := &setOnlyController{}
+			Expect(serveRequest(cf_debug_server.Handler(ctrl), http.MethodPost, "/log-level", "warn").Code).To(Equal(http.StatusOK))
+			Expect(ctrl.level).To(Equal(lager.LogLevel(99)))
+		})
+	})
+
+	Describe("zap", func() {
+		It("sets the native level and reads it back", func() {
+			atom := &atomicZapLevel{}
+			handler := cf_debug_server.Handler(nil, cf_debug_server.WithLevelController(cf_debug_server.NewZapLevelController(atom.SetLevel, atom.Level)))
+
+			Expect(serveRequest(handler, http.MethodPost, "/log-level", "warn").Code).To(Equal(http.StatusOK))
+			Expect(atom.level).To(Equal(zapLevel(1)))
+			Expect(serveRequest(handler, http.MethodGet, "/log-level", "").Body.String()).To(Equal("warn\n"))
+
+			Expect(serveRequest(handler, http.MethodPost, "/log-level", "fatal").Code).To(Equal(http.StatusOK))
+			Expect(atom.level).To(Equal(zapLevel(5)))
+
+			// zap's panic level
+			atom.SetLevel(4)
+			Expect(serveRequest(handler, http.MethodGet, "/log-level", "").Body.String()).To(Equal("error+2\n"))
+		})
+	})
+})
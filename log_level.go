@@ -0,0 +1,175 @@
+package debugserver
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	lager "code.cloudfoundry.org/lager/v3"
+)
+
+// logLevelHandler serves /log-level: GET reports the current level, POST sets a new one.
+// A POST may carry a duration after which the previous level is restored.
+func logLevelHandler(override *logLevelOverride) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			getLogLevel(w, r, override)
+			return
+		}
+		setLogLevel(w, r, override)
+	}
+}
+
+func getLogLevel(w http.ResponseWriter, r *http.Request, override *logLevelOverride) {
+	getter, ok := override.ctrl.(LevelGetter)
+	if !ok {
+		writeControlError(w, r, notImplemented("log controller does not support reading the log level"))
+		return
+	}
+	level := getter.GetLevel().String()
+	pending := override.pending()
+
+	if jsonControlMode(r) {
+		writeJSON(w, http.StatusOK, struct {
+			Current  string                 `json:"current"`
+			Override *pendingLogLevelRevert `json:"override,omitempty"`
+		}{level, pending})
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	// #nosec G104 - ignore errors writing http response to avoid spamming logs during DoS
+	w.Write([]byte(level + "\n"))
+	if pending != nil {
+		// #nosec G104 - ignore errors writing http response to avoid spamming logs during DoS
+		w.Write([]byte(pending.String() + "\n"))
+	}
+}
+
+func setLogLevel(w http.ResponseWriter, r *http.Request, override *logLevelOverride) {
+	if !isWriteMethod(r) {
+		writeControlError(w, r, methodNotAllowed(methodNotAllowedMsg))
+		return
+	}
+	// Read the log level from the request body.
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeControlError(w, r, invalidBody("Failed to read body"))
+		return
+	}
+	level, ttl, err := parseLogLevelRequest(r, body)
+	if err != nil {
+		writeControlError(w, r, err)
+		return
+	}
+	// Validate the log level request.
+	var normalizedLevel string
+	if normalizedLevel, err = validateAndNormalize(w, r, level); err != nil {
+		writeControlError(w, r, invalidValue("%s", err))
+		return
+	}
+	newLevel, err := ParseLevel(normalizedLevel)
+	if err != nil {
+		writeControlError(w, r, invalidValue("%s", err))
+		return
+	}
+
+	auditData := lager.Data{"new_level": normalizedLevel}
+	var previous string
+	if getter, ok := override.ctrl.(LevelGetter); ok {
+		previous = getter.GetLevel().String()
+		auditData["old_level"] = previous
+	}
+	if ttl > 0 {
+		auditData["ttl"] = ttl.String()
+	}
+
+	var pending *pendingLogLevelRevert
+	if ttl > 0 {
+		if pending, err = override.setFor(newLevel, ttl); err != nil {
+			writeControlError(w, r, notImplemented(err.Error()))
+			return
+		}
+	} else {
+		override.set(newLevel)
+	}
+	addAuditParams(r, auditData)
+	if jsonControlMode(r) {
+		writeJSON(w, http.StatusOK, struct {
+			Previous string                 `json:"previous,omitempty"`
+			Current  string                 `json:"current"`
+			Override *pendingLogLevelRevert `json:"override,omitempty"`
+		}{previous, normalizedLevel, pending})
+		return
+	}
+	// Respond with a success message.
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("/log-level was invoked with Level: " + normalizedLevel + "\n"))
+	if pending != nil {
+		w.Write([]byte(pending.String() + "\n"))
+	}
+	if normalizedLevel == "fatal" {
+		w.Write([]byte("Note: Fatal logs are reported as error logs in the Gorouter logs.\n"))
+	}
+}
+
+// parseLogLevelRequest extracts the level and optional override duration from a /log-level body.
+// Plain text bodies look like "debug" or "debug for=15m"; JSON bodies look like {"level":"debug","ttl":"15m"}.
+func parseLogLevelRequest(r *http.Request, body []byte) ([]byte, time.Duration, error) {
+	var level, ttl string
+	if hasJSONBody(r) {
+		var req struct {
+			Level string `json:"level"`
+			TTL   string `json:"ttl"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, 0, invalidBody("invalid JSON body: %s", err)
+		}
+		level, ttl = req.Level, req.TTL
+	} else {
+		fields := strings.Fields(string(body))
+		if len(fields) > 0 {
+			level = fields[0]
+		}
+		for _, field := range fields[min(1, len(fields)):] {
+			value, ok := strings.CutPrefix(field, "for=")
+			if !ok {
+				return nil, 0, invalidBody("invalid log level option: %s", field)
+			}
+			ttl = value
+		}
+	}
+
+	if ttl == "" {
+		return []byte(level), 0, nil
+	}
+	duration, err := time.ParseDuration(ttl)
+	if err != nil || duration <= 0 {
+		return nil, 0, invalidValue("invalid log level duration: %s", ttl)
+	}
+	return []byte(level), duration, nil
+}
+
+// wantsJSON reports whether the client asked for a JSON response.
+func wantsJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// wantsJSONFormat picks JSON or text output from ?format=json|text, or from the
+// Accept header when no format is given.
+func wantsJSONFormat(r *http.Request) (bool, error) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "json":
+		return true, nil
+	case "text":
+		return false, nil
+	case "":
+		return wantsJSON(r), nil
+	default:
+		return false, errors.New("invalid format " + strconv.Quote(format) + ", use json or text")
+	}
+}
@@ -0,0 +1,103 @@
+package debugserver
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// logLevelOverride sets log levels on behalf of /log-level and restores the previous
+// level once a time-limited override expires.
+//
+// Overlapping overrides replace the expiry of the pending one but keep its revert level,
+// so the level that was active before the first override is always the one restored.
+// Setting a level without a duration cancels any pending override.
+type logLevelOverride struct {
+	ctrl LevelController
+
+	mu     sync.Mutex
+	timer  *time.Timer
+	revert *pendingLogLevelRevert
+}
+
+// pendingLogLevelRevert describes an override that has not expired yet.
+type pendingLogLevelRevert struct {
+	RevertTo  string    `json:"revert_to"`
+	ExpiresAt time.Time `json:"expires_at"`
+
+	level Level
+}
+
+func (p *pendingLogLevelRevert) String() string {
+	return fmt.Sprintf("Level reverts to %s at %s", p.RevertTo, p.ExpiresAt.UTC().Format(time.RFC3339))
+}
+
+// set changes the level permanently, cancelling any pending override.
+func (o *logLevelOverride) set(level Level) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.cancelLocked()
+	o.ctrl.SetLevel(level)
+}
+
+// setFor changes the level until ttl has passed.
+func (o *logLevelOverride) setFor(level Level, ttl time.Duration) (*pendingLogLevelRevert, error) {
+	getter, ok := o.ctrl.(LevelGetter)
+	if !ok {
+		return nil, errors.New("log controller does not support reading the log level, which is required to revert it")
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	revertTo := getter.GetLevel()
+	if o.revert != nil {
+		revertTo = o.revert.level
+	}
+	o.cancelLocked()
+
+	revert := &pendingLogLevelRevert{
+		RevertTo:  revertTo.String(),
+		ExpiresAt: time.Now().Add(ttl),
+		level:     revertTo,
+	}
+	o.revert = revert
+	o.timer = time.AfterFunc(ttl, func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+
+		// The override was cancelled or replaced while the timer was firing.
+		if o.revert != revert {
+			return
+		}
+		o.ctrl.SetLevel(revert.level)
+		o.revert = nil
+		o.timer = nil
+	})
+	o.ctrl.SetLevel(level)
+
+	pending := *revert
+	return &pending, nil
+}
+
+// pending returns a copy of the pending override, or nil if there is none.
+func (o *logLevelOverride) pending() *pendingLogLevelRevert {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.revert == nil {
+		return nil
+	}
+	pending := *o.revert
+	return &pending
+}
+
+func (o *logLevelOverride) cancelLocked() {
+	if o.timer != nil {
+		o.timer.Stop()
+	}
+	o.timer = nil
+	o.revert = nil
+}
@@ -0,0 +1,166 @@
+package debugserver_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	cf_debug_server "code.cloudfoundry.org/debugserver"
+	lager "code.cloudfoundry.org/lager/v3"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type setOnlyController struct {
+	level lager.LogLevel
+}
+
+func (c *setOnlyController) SetMinLevel(level lager.LogLevel) {
+	c.level = level
+}
+
+var _ = Describe("/log-level", func() {
+	var (
+		sink    *lager.ReconfigurableSink
+		handler http.Handler
+	)
+
+	BeforeEach(func() {
+		sink = lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.INFO)
+		handler = cf_debug_server.Handler(&cf_debug_server.LagerAdapter{Sink: sink})
+	})
+
+	Describe("POST", func() {
+		It("sets the log level", func() {
+			rec := serveRequest(handler, http.MethodPost, "/log-level", "error")
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Header().Get("Content-Type")).To(Equal("text/plain"))
+			Expect(rec.Body.String()).To(ContainSubstring("Level: error"))
+			Expect(sink.GetMinLevel()).To(Equal(lager.ERROR))
+		})
+	})
+
+	Describe("POST with a duration", func() {
+		It("restores the previous level when the duration passes", func() {
+			rec := serveRequest(handler, http.MethodPost, "/log-level", "debug for=200ms")
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Body.String()).To(ContainSubstring("Level reverts to info at"))
+			Expect(sink.GetMinLevel()).To(Equal(lager.DEBUG))
+
+			Eventually(sink.GetMinLevel).Should(Equal(lager.INFO))
+		})
+
+		It("accepts a JSON body with a ttl", func() {
+			rec := serveRequest(handler, http.MethodPost, "/log-level", `{"level":"error","ttl":"200ms"}`, "Content-Type", "application/json")
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(sink.GetMinLevel()).To(Equal(lager.ERROR))
+
+			Eventually(sink.GetMinLevel).Should(Equal(lager.INFO))
+		})
+
+		It("shows the pending override in a GET", func() {
+			Expect(serveRequest(handler, http.MethodPost, "/log-level", "debug for=1h").Code).To(Equal(http.StatusOK))
+
+			rec := serveRequest(handler, http.MethodGet, "/log-level", "")
+			Expect(rec.Body.String()).To(HavePrefix("debug\nLevel reverts to info at "))
+
+			rec = serveRequest(handler, http.MethodGet, "/log-level", "", "Accept", "application/json")
+			var body struct {
+				Current  string `json:"current"`
+				Override struct {
+					RevertTo  string    `json:"revert_to"`
+					ExpiresAt time.Time `json:"expires_at"`
+				} `json:"override"`
+			}
+			Expect(json.Unmarshal(rec.Body.Bytes(), &body)).To(Succeed())
+			Expect(body.Current).To(Equal("debug"))
+			Expect(body.Override.RevertTo).To(Equal("info"))
+			Expect(body.Override.ExpiresAt).To(BeTemporally("~", time.Now().Add(time.Hour), time.Minute))
+		})
+
+		It("is cancelled by a POST without a duration", func() {
+			Expect(serveRequest(handler, http.MethodPost, "/log-level", "debug for=200ms").Code).To(Equal(http.StatusOK))
+			Expect(serveRequest(handler, http.MethodPost, "/log-level", "error").Code).To(Equal(http.StatusOK))
+
+			Expect(serveRequest(handler, http.MethodGet, "/log-level", "").Body.String()).To(Equal("error\n"))
+			Consistently(sink.GetMinLevel, "400ms").Should(Equal(lager.ERROR))
+		})
+
+		It("reverts overlapping overrides to the level before the first one", func() {
+			Expect(serveRequest(handler, http.MethodPost, "/log-level", "debug for=1h").Code).To(Equal(http.StatusOK))
+			Expect(serveRequest(handler, http.MethodPost, "/log-level", "warn for=200ms").Code).To(Equal(http.StatusOK))
+			Expect(serveRequest(handler, http.MethodGet, "/log-level", "").Body.String()).To(ContainSubstring("Level reverts to info"))
+
+			Eventually(sink.GetMinLevel).Should(Equal(lager.INFO))
+			Consistently(sink.GetMinLevel, "200ms").Should(Equal(lager.INFO))
+		})
+
+		DescribeTable("rejects invalid durations",
+			func(body string) {
+				rec := serveRequest(handler, http.MethodPost, "/log-level", body)
+				Expect(rec.Code).To(Equal(http.StatusBadRequest))
+				Expect(sink.GetMinLevel()).To(Equal(lager.INFO))
+			},
+			Entry("not a duration", "debug for=soon"),
+			Entry("negative", "debug for=-1m"),
+			Entry("unknown option", "debug until=1m"),
+		)
+
+		Context("when the controller cannot report its level", func() {
+			BeforeEach(func() {
+				handler = cf_debug_server.Handler(&setOnlyController{})
+			})
+
+			It("refuses the override", func() {
+				rec := serveRequest(handler, http.MethodPost, "/log-level", "debug for=1m")
+				Expect(rec.Code).To(Equal(http.StatusNotImplemented))
+			})
+		})
+	})
+
+	Describe("GET", func() {
+		DescribeTable("returns the current normalized level",
+			func(input, expected string) {
+				Expect(serveRequest(handler, http.MethodPost, "/log-level", input).Code).To(Equal(http.StatusOK))
+
+				rec := serveRequest(handler, http.MethodGet, "/log-level", "")
+				Expect(rec.Code).To(Equal(http.StatusOK))
+				Expect(rec.Body.String()).To(Equal(expected + "\n"))
+			},
+			Entry("debug", "d", "debug"),
+			Entry("info", "1", "info"),
+			Entry("warn", "warn", "warn"),
+			Entry("error", "ERROR", "error"),
+			Entry("fatal", "f", "fatal"),
+		)
+
+		It("returns JSON when requested", func() {
+			sink.SetMinLevel(lager.DEBUG)
+
+			rec := serveRequest(handler, http.MethodGet, "/log-level", "", "Accept", "application/json")
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Header().Get("Content-Type")).To(Equal("application/json"))
+			Expect(rec.Body.String()).To(MatchJSON(`{"current":"debug"}`))
+		})
+
+		It("reports levels without a name as an offset from the nearest named one", func() {
+			sink.SetMinLevel(lager.FATAL + 1)
+
+			rec := serveRequest(handler, http.MethodGet, "/log-level", "")
+			Expect(rec.Body.String()).To(Equal("fatal+1\n"))
+		})
+
+		Context("when the controller cannot report its level", func() {
+			BeforeEach(func() {
+				handler = cf_debug_server.Handler(&setOnlyController{})
+			})
+
+			It("returns not implemented", func() {
+				rec := serveRequest(handler, http.MethodGet, "/log-level", "")
+				Expect(rec.Code).To(Equal(http.StatusNotImplemented))
+			})
+		})
+	})
+})
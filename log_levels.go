@@ -0,0 +1,204 @@
+package debugserver
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	lager "code.cloudfoundry.org/lager/v3"
+)
+
+var logControllerNameRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// WithNamedLogController serves /log-level/NAME for a log controller of its own, such as
+// the sink of the access log, next to the one of WithLogController. /log-levels lists
+// the levels of all log controllers and sets them all at once.
+func WithNamedLogController(name string, zapCtrl zapLogLevelController) Option {
+	return func(o *options) {
+		o.namedLogControllers = append(o.namedLogControllers, namedLogController{name: name, ctrl: levelControllerFor(zapCtrl)})
+	}
+}
+
+type namedLogController struct {
+	name string
+	ctrl LevelController
+}
+
+// checkLogControllerNames rejects names that cannot be used in /log-level/NAME.
+func (o *options) checkLogControllerNames() error {
+	seen := map[string]bool{}
+	for _, ctrl := range o.namedLogControllers {
+		if !logControllerNameRegexp.MatchString(ctrl.name) {
+			return fmt.Errorf("invalid log controller name %q, use up to 64 letters, digits, '.', '_' or '-'", ctrl.name)
+		}
+		if ctrl.ctrl == nil {
+			return fmt.Errorf("log controller %s is nil", ctrl.name)
+		}
+		if seen[ctrl.name] {
+			return fmt.Errorf("log controller %s is registered twice", ctrl.name)
+		}
+		seen[ctrl.name] = true
+	}
+	return nil
+}
+
+// logLevelTarget is a log controller served by the debug server, together with the
+// overrides made through its endpoint.
+type logLevelTarget struct {
+	name     string
+	override *logLevelOverride
+}
+
+// path is /log-level for the controller of WithLogController and /log-level/NAME otherwise.
+func (t *logLevelTarget) path() string {
+	if t.name == "" {
+		return "/log-level"
+	}
+	return "/log-level/" + t.name
+}
+
+// logLevelTargets returns the log controllers of the options, the unnamed one first and
+// the named ones sorted by name.
+func logLevelTargets(o *options) []*logLevelTarget {
+	var targets []*logLevelTarget
+	if o.logController != nil {
+		targets = append(targets, &logLevelTarget{override: &logLevelOverride{ctrl: o.logController}})
+	}
+	named := append([]namedLogController(nil), o.namedLogControllers...)
+	sort.Slice(named, func(i, j int) bool { return named[i].name < named[j].name })
+	for _, ctrl := range named {
+		targets = append(targets, &logLevelTarget{name: ctrl.name, override: &logLevelOverride{ctrl: ctrl.ctrl}})
+	}
+	return targets
+}
+
+// logLevelStatus is the level of one log controller, as listed by /log-levels, together
+// with the level it had before when it was just set, named like the previous and current
+// values of the other control endpoints.
+type logLevelStatus struct {
+	Name     string                 `json:"name,omitempty"`
+	Path     string                 `json:"path"`
+	Previous string                 `json:"previous,omitempty"`
+	Current  string                 `json:"current"`
+	Override *pendingLogLevelRevert `json:"override,omitempty"`
+}
+
+// logLevelsHandler serves /log-levels: GET lists the level of every log controller, POST
+// or PUT sets all of them to the level in the body, which is read like the body of /log-level.
+func logLevelsHandler(targets []*logLevelTarget) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			listLogLevels(w, r, targets)
+		case http.MethodPost, http.MethodPut:
+			setAllLogLevels(w, r, targets)
+		default:
+			writeControlError(w, r, methodNotAllowed(methodNotAllowedMsg))
+		}
+	}
+}
+
+func listLogLevels(w http.ResponseWriter, r *http.Request, targets []*logLevelTarget) {
+	asJSON, err := wantsJSONFormat(r)
+	if err != nil {
+		writeControlError(w, r, invalidValue("%s", err))
+		return
+	}
+	writeLogLevels(w, asJSON, logLevelStatuses(targets, nil))
+}
+
+func setAllLogLevels(w http.ResponseWriter, r *http.Request, targets []*logLevelTarget) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeControlError(w, r, invalidBody("Failed to read body"))
+		return
+	}
+	level, ttl, err := parseLogLevelRequest(r, body)
+	if err != nil {
+		writeControlError(w, r, err)
+		return
+	}
+	newLevel, err := ParseLevel(string(level))
+	if err != nil {
+		writeControlError(w, r, invalidValue("%s", err))
+		return
+	}
+	// A timed override has to read the level to revert to, so check every controller
+	// before changing any of them.
+	if ttl > 0 {
+		for _, target := range targets {
+			if _, ok := target.override.ctrl.(LevelGetter); !ok {
+				writeControlError(w, r, notImplemented("log controller at "+target.path()+" does not support reading the log level, which is required to revert it"))
+				return
+			}
+		}
+	}
+
+	auditData := lager.Data{"new_level": newLevel.String(), "controllers": len(targets)}
+	if ttl > 0 {
+		auditData["ttl"] = ttl.String()
+	}
+	previous := logLevelStatuses(targets, nil)
+	for _, target := range targets {
+		if ttl > 0 {
+			// #nosec G104 - the controllers were checked for a level getter above
+			target.override.setFor(newLevel, ttl)
+		} else {
+			target.override.set(newLevel)
+		}
+	}
+	addAuditParams(r, auditData)
+
+	writeLogLevels(w, jsonControlMode(r), logLevelStatuses(targets, previous))
+}
+
+// logLevelStatuses reads the level of every target. previous, if given, holds the
+// statuses from before a change, whose levels are reported as the previous ones.
+func logLevelStatuses(targets []*logLevelTarget, previous []logLevelStatus) []logLevelStatus {
+	statuses := make([]logLevelStatus, 0, len(targets))
+	for i, target := range targets {
+		status := logLevelStatus{Name: target.name, Path: target.path(), Override: target.override.pending()}
+		if getter, ok := target.override.ctrl.(LevelGetter); ok {
+			status.Current = getter.GetLevel().String()
+		}
+		if previous != nil {
+			status.Previous = previous[i].Current
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+func writeLogLevels(w http.ResponseWriter, asJSON bool, statuses []logLevelStatus) {
+	if asJSON {
+		writeJSON(w, http.StatusOK, struct {
+			LogLevels []logLevelStatus `json:"log_levels"`
+		}{statuses})
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	// #nosec G104 - ignore errors writing http response to avoid spamming logs during DoS
+	writeLogLevelsText(w, statuses)
+}
+
+// writeLogLevelsText writes one line per log controller with its path, level and
+// pending revert, lined up in columns.
+func writeLogLevelsText(w io.Writer, statuses []logLevelStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, status := range statuses {
+		level := status.Current
+		if level == "" {
+			level = "unknown"
+		}
+		line := []string{status.Path, level}
+		if status.Override != nil {
+			line = append(line, status.Override.String())
+		}
+		fmt.Fprintln(tw, strings.Join(line, "\t"))
+	}
+	return tw.Flush()
+}
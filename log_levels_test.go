@@ -0,0 +1,93 @@
+package debugserver_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	cf_debug_server "code.cloudfoundry.org/debugserver"
+	lager "code.cloudfoundry.org/lager/v3"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Named log controllers", func() {
+	var (
+		appSink, accessSink, routesSink *lager.ReconfigurableSink
+		handler                         http.Handler
+	)
+
+	BeforeEach(func() {
+		appSink = lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.INFO)
+		accessSink = lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.INFO)
+		routesSink = lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.ERROR)
+		handler = cf_debug_server.Handler(appSink,
+			cf_debug_server.WithNamedLogController("routes", routesSink),
+			cf_debug_server.WithNamedLogController("access", accessSink),
+		)
+	})
+
+	It("sets the level of one controller on /log-level/NAME", func() {
+		Expect(serveRequest(handler, http.MethodPost, "/log-level/access", "debug").Code).To(Equal(http.StatusOK))
+		Expect(accessSink.GetMinLevel()).To(Equal(lager.DEBUG))
+		Expect(appSink.GetMinLevel()).To(Equal(lager.INFO))
+		Expect(routesSink.GetMinLevel()).To(Equal(lager.ERROR))
+
+		Expect(serveRequest(handler, http.MethodGet, "/log-level/access", "").Body.String()).To(Equal("debug\n"))
+	})
+
+	It("lists the controllers and their levels", func() {
+		rec := serveRequest(handler, http.MethodGet, "/log-levels?format=json", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+
+		var response struct {
+			LogLevels []struct {
+				Name    string `json:"name"`
+				Path    string `json:"path"`
+				Current string `json:"current"`
+			} `json:"log_levels"`
+		}
+		Expect(json.Unmarshal(rec.Body.Bytes(), &response)).To(Succeed())
+		Expect(response.LogLevels).To(HaveLen(3))
+		Expect(response.LogLevels[0].Path).To(Equal("/log-level"))
+		Expect(response.LogLevels[0].Current).To(Equal("info"))
+		Expect(response.LogLevels[1].Name).To(Equal("access"))
+		Expect(response.LogLevels[1].Path).To(Equal("/log-level/access"))
+		Expect(response.LogLevels[2].Name).To(Equal("routes"))
+		Expect(response.LogLevels[2].Current).To(Equal("error"))
+
+		Expect(serveRequest(handler, http.MethodGet, "/log-levels", "").Body.String()).To(MatchRegexp(`/log-level/routes\s+error`))
+	})
+
+	It("sets every controller at once on /log-levels", func() {
+		rec := serveRequest(handler, http.MethodPost, "/log-levels", `{"level":"debug","ttl":"1h"}`, "Content-Type", "application/json")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(ContainSubstring("/log-level/routes"))
+
+		Expect(appSink.GetMinLevel()).To(Equal(lager.DEBUG))
+		Expect(accessSink.GetMinLevel()).To(Equal(lager.DEBUG))
+		Expect(routesSink.GetMinLevel()).To(Equal(lager.DEBUG))
+		Expect(serveRequest(handler, http.MethodGet, "/log-level/routes", "").Body.String()).To(ContainSubstring("Level reverts to error"))
+	})
+
+	It("rejects an invalid level without changing any controller", func() {
+		Expect(serveRequest(handler, http.MethodPost, "/log-levels", "loud").Code).To(Equal(http.StatusBadRequest))
+		Expect(routesSink.GetMinLevel()).To(Equal(lager.ERROR))
+	})
+
+	It("lists the named endpoints on the index page", func() {
+		Expect(serveRequest(handler, http.MethodGet, "/", "").Body.String()).To(ContainSubstring("/log-level/access"))
+	})
+
+	It("rejects names that cannot be used in a path", func() {
+		_, err := cf_debug_server.New(cf_debug_server.WithNamedLogController("access log", accessSink))
+		Expect(err).To(MatchError(ContainSubstring(`invalid log controller name "access log"`)))
+
+		_, err = cf_debug_server.New(
+			cf_debug_server.WithNamedLogController("access", accessSink),
+			cf_debug_server.WithNamedLogController("access", routesSink),
+		)
+		Expect(err).To(MatchError(ContainSubstring("log controller access is registered twice")))
+	})
+})
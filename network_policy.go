@@ -0,0 +1,164 @@
+package debugserver
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	lager "code.cloudfoundry.org/lager/v3"
+)
+
+// WithAllowedNetworks only serves requests from clients in the given networks, written as
+// CIDRs such as "10.0.16.0/20" or single addresses such as "127.0.0.1". Other clients get
+// 403 Forbidden. Requests over a unix socket carry no client address and are always served.
+func WithAllowedNetworks(networks ...string) Option {
+	return func(o *options) {
+		o.allowedNetworks = append(o.allowedNetworks, networks...)
+	}
+}
+
+// WithTrustedProxies takes the client address of requests from the given networks from
+// their X-Forwarded-For header, as the last address in it that is not a trusted proxy.
+// Without it the header is ignored, since any client can set it.
+func WithTrustedProxies(networks ...string) Option {
+	return func(o *options) {
+		o.trustedProxies = append(o.trustedProxies, networks...)
+	}
+}
+
+// WithStrictNetworkPolicy makes the server refuse to start on an address that is not a
+// loopback address or unix socket unless token auth or TLS is configured, so that a
+// debug address of 0.0.0.0:17017 cannot expose the endpoints by accident.
+func WithStrictNetworkPolicy() Option {
+	return func(o *options) {
+		o.strictNetworkPolicy = true
+	}
+}
+
+// networkPolicy decides which clients may use the debug server.
+type networkPolicy struct {
+	allowed []netip.Prefix
+	proxies []netip.Prefix
+	logger  lager.Logger
+}
+
+func newNetworkPolicy(o *options) (*networkPolicy, error) {
+	allowed, err := parseNetworks(o.allowedNetworks)
+	if err != nil {
+		return nil, fmt.Errorf("invalid allowed network: %w", err)
+	}
+	proxies, err := parseNetworks(o.trustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxy: %w", err)
+	}
+	return &networkPolicy{allowed: allowed, proxies: proxies, logger: o.logger}, nil
+}
+
+// parseNetworks parses CIDRs and single addresses.
+func parseNetworks(networks []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, network := range networks {
+		network = strings.TrimSpace(network)
+		if prefix, err := netip.ParsePrefix(network); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(network)
+		if err != nil {
+			return nil, fmt.Errorf("%q is neither a CIDR nor an IP address", network)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// wrap records the client address in the audit record and refuses requests from clients
+// outside the allowed networks, written like the errors of the control endpoints.
+func (p *networkPolicy) wrap(h http.Handler) http.Handler {
+	if len(p.allowed) == 0 && len(p.proxies) == 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client, ok := p.clientAddr(r)
+		if !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		addAuditParams(r, lager.Data{"client_ip": client.String()})
+		if len(p.allowed) == 0 || containsAddr(p.allowed, client) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if p.logger != nil {
+			p.logger.Info("request-denied", lager.Data{
+				"remote_addr": r.RemoteAddr,
+				"client_ip":   client.String(),
+				"endpoint":    r.URL.Path,
+				"reason":      "client is not in the allowed networks",
+			})
+		}
+		writeControlError(w, r, forbidden("client "+client.String()+" is not allowed to use the debug server"))
+	})
+}
+
+// clientAddr returns the address of the client, looking through trusted proxies.
+// It returns false for requests without a client address, such as over a unix socket.
+func (p *networkPolicy) clientAddr(r *http.Request) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	client, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	client = client.Unmap()
+
+	if len(p.proxies) == 0 || !containsAddr(p.proxies, client) {
+		return client, true
+	}
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			// An unparseable hop cannot be trusted to lead to the client.
+			return client, true
+		}
+		client = hop.Unmap()
+		if !containsAddr(p.proxies, client) {
+			return client, true
+		}
+	}
+	return client, true
+}
+
+// checkStrictNetworkPolicy refuses listeners that are reachable from other hosts when
+// neither token auth nor TLS protects the endpoints.
+func (o *options) checkStrictNetworkPolicy(addr net.Addr) error {
+	if !o.strictNetworkPolicy || o.auth != nil || o.tlsConfig != nil {
+		return nil
+	}
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok || tcpAddr.IP.IsLoopback() {
+		return nil
+	}
+	where := addr.String()
+	if o.address != "" {
+		where = o.address
+	}
+	return fmt.Errorf("debug server refuses to listen on %s in strict network policy mode: "+
+		"use a loopback address or unix socket, or configure token auth or TLS", where)
+}
@@ -0,0 +1,120 @@
+package debugserver_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	cf_debug_server "code.cloudfoundry.org/debugserver"
+	lager "code.cloudfoundry.org/lager/v3"
+	"github.com/tedsuo/ifrit"
+	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Network policy", func() {
+	var sink *lager.ReconfigurableSink
+
+	BeforeEach(func() {
+		sink = lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.INFO)
+	})
+
+	Describe("WithAllowedNetworks", func() {
+		var (
+			logs    *bytes.Buffer
+			handler http.Handler
+		)
+
+		BeforeEach(func() {
+			logs = &bytes.Buffer{}
+			logger := lager.NewLogger("test")
+			logger.RegisterSink(lager.NewWriterSink(logs, lager.DEBUG))
+			handler = cf_debug_server.Handler(sink,
+				cf_debug_server.WithLogger(logger),
+				cf_debug_server.WithAllowedNetworks("10.0.0.0/8", "192.0.2.7"),
+				cf_debug_server.WithTrustedProxies("10.0.0.1"),
+			)
+		})
+
+		serve := func(remoteAddr string, forwardedFor ...string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, "/log-level", nil)
+			req.RemoteAddr = remoteAddr
+			for _, value := range forwardedFor {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			return rec
+		}
+
+		It("serves clients in the allowed networks", func() {
+			Expect(serve("10.1.2.3:5555").Code).To(Equal(http.StatusOK))
+			Expect(serve("192.0.2.7:5555").Code).To(Equal(http.StatusOK))
+		})
+
+		It("refuses other clients and logs why", func() {
+			rec := serve("192.0.2.8:5555")
+			Expect(rec.Code).To(Equal(http.StatusForbidden))
+			Expect(rec.Body.String()).To(ContainSubstring("client 192.0.2.8 is not allowed to use the debug server"))
+			Expect(logs.String()).To(ContainSubstring(`"message":"test.debug-server.request-denied"`))
+			Expect(logs.String()).To(ContainSubstring(`"client_ip":"192.0.2.8"`))
+		})
+
+		It("serves requests without a client address, which come over a unix socket", func() {
+			Expect(serve("@").Code).To(Equal(http.StatusOK))
+		})
+
+		It("takes the client address from X-Forwarded-For of trusted proxies only", func() {
+			Expect(serve("10.0.0.1:5555", "203.0.113.9, 10.0.0.1").Code).To(Equal(http.StatusForbidden))
+			Expect(serve("10.0.0.1:5555", "203.0.113.9", "192.0.2.7").Code).To(Equal(http.StatusOK))
+			Expect(serve("192.0.2.8:5555", "10.2.3.4").Code).To(Equal(http.StatusForbidden))
+		})
+
+		It("rejects invalid networks", func() {
+			_, err := cf_debug_server.New(cf_debug_server.WithAllowedNetworks("10.0.0.0/33"))
+			Expect(err).To(MatchError(ContainSubstring(`"10.0.0.0/33" is neither a CIDR nor an IP address`)))
+		})
+	})
+
+	Describe("WithStrictNetworkPolicy", func() {
+		var process ifrit.Process
+
+		BeforeEach(func() {
+			process = nil
+		})
+
+		AfterEach(func() {
+			if process != nil {
+				ginkgomon.Interrupt(process)
+			}
+		})
+
+		It("refuses to listen on all interfaces without auth or TLS", func() {
+			_, err := cf_debug_server.Run("0.0.0.0:0", sink, cf_debug_server.WithStrictNetworkPolicy())
+			Expect(err).To(MatchError(ContainSubstring("refuses to listen on 0.0.0.0:")))
+		})
+
+		It("listens on loopback addresses", func() {
+			var err error
+			process, err = cf_debug_server.Run("127.0.0.1:0", sink, cf_debug_server.WithStrictNetworkPolicy())
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("listens on all interfaces with token auth", func() {
+			tokenFile := filepath.Join(GinkgoT().TempDir(), "token")
+			Expect(os.WriteFile(tokenFile, []byte("secret"), 0600)).To(Succeed())
+
+			var err error
+			process, err = cf_debug_server.Run("0.0.0.0:0", sink,
+				cf_debug_server.WithStrictNetworkPolicy(),
+				cf_debug_server.WithTokenAuth(tokenFile, cf_debug_server.AuthAllEndpoints),
+			)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,68 @@
+package debugserver
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	lager "code.cloudfoundry.org/lager/v3"
+	"github.com/tedsuo/ifrit"
+)
+
+// Option configures optional behavior of the debug server handler.
+type Option func(*options)
+
+type options struct {
+	address       string
+	listener      net.Listener
+	tlsConfig     *tls.Config
+	socketOpts    UnixSocketOptions
+	timeouts      Timeouts
+	logController LevelController
+	// namedLogControllers are served on /log-level/NAME.
+	namedLogControllers []namedLogController
+
+	auth                *tokenAuth
+	allowedNetworks     []string
+	trustedProxies      []string
+	strictNetworkPolicy bool
+	logger              lager.Logger
+	middleware          []func(http.Handler) http.Handler
+	enabled             map[string]bool
+	maxProfileDuration  time.Duration
+	profileQueueTimeout time.Duration
+	flightRecorder      *FlightRecorder
+	leakDetector        *GoroutineLeakDetector
+	versionFields       []versionField
+	endpoints           []registeredEndpoint
+
+	// sidecars run alongside the debug server when it is started with Runner.
+	sidecars []ifrit.Runner
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(o)
+		}
+	}
+	return o
+}
+
+// read wraps endpoints that only report on the process.
+func (o *options) read(h http.Handler) http.Handler {
+	if o.auth != nil && o.auth.scope == AuthAllEndpoints {
+		return o.auth.wrap(h)
+	}
+	return h
+}
+
+// control wraps endpoints that change the behavior of the process.
+func (o *options) control(h http.Handler) http.Handler {
+	if o.auth != nil {
+		return o.auth.wrap(h)
+	}
+	return h
+}
@@ -0,0 +1,285 @@
+package debugserver
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WithMaxProfileDuration refuses requests for profiles and traces longer than max,
+// such as /debug/pprof/profile?seconds=600. Zero means no limit.
+func WithMaxProfileDuration(max time.Duration) Option {
+	return func(o *options) {
+		o.maxProfileDuration = max
+	}
+}
+
+// WithProfileQueueTimeout makes a request for a CPU profile or execution trace wait up
+// to timeout for the one in progress to finish. Without it, or when the wait is over,
+// the request is answered with 429 Too Many Requests.
+func WithProfileQueueTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.profileQueueTimeout = timeout
+	}
+}
+
+// profileKind names a capture the runtime runs only one of at a time.
+type profileKind string
+
+const (
+	cpuProfileKind     profileKind = "cpu"
+	executionTraceKind profileKind = "trace"
+)
+
+// profileParam is a form value of a profile endpoint giving a duration in seconds.
+// kind is the capture the duration is for, if it needs one to itself, and def the
+// duration the endpoint uses when the parameter is missing or invalid. whole is set
+// for endpoints that only read whole seconds, as pprof.Profile does with ParseInt;
+// the others read fractions of a second, as pprof.Trace does with ParseFloat.
+type profileParam struct {
+	name  string
+	kind  profileKind
+	def   time.Duration
+	whole bool
+}
+
+// duration reads the parameter the way the endpoint does, so that the duration checked
+// against the maximum is the one the endpoint runs for.
+func (p profileParam) duration(r *http.Request) time.Duration {
+	value := r.FormValue(p.name)
+	var seconds float64
+	if p.whole {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return p.def
+		}
+		seconds = float64(n)
+	} else {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return p.def
+		}
+		seconds = f
+	}
+	if !(seconds > 0) {
+		return p.def
+	}
+	if seconds >= float64(math.MaxInt64)/float64(time.Second) {
+		return math.MaxInt64
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// profileCapture is a CPU profile or execution trace, taken for a request or by the
+// watchdog, as shown on /debug/captures.
+type profileCapture struct {
+	ID         int           `json:"id"`
+	Request    string        `json:"request"`
+	RemoteAddr string        `json:"remote_addr,omitempty"`
+	Kinds      []profileKind `json:"kinds"`
+	Duration   Duration      `json:"duration"`
+	State      string        `json:"state"`
+	QueuedAt   time.Time     `json:"queued_at"`
+	StartedAt  *time.Time    `json:"started_at,omitempty"`
+}
+
+// profileCaptures hands out the CPU profile and execution trace of the runtime to one
+// capture at a time. It is shared by all debug servers in the process.
+type profileCaptures struct {
+	slots map[profileKind]chan struct{}
+
+	mu       sync.Mutex
+	nextID   int
+	captures map[int]*profileCapture
+}
+
+var captures = &profileCaptures{
+	slots: map[profileKind]chan struct{}{
+		cpuProfileKind:     make(chan struct{}, 1),
+		executionTraceKind: make(chan struct{}, 1),
+	},
+	captures: map[int]*profileCapture{},
+}
+
+// profileBusyError is returned when a capture is in progress for too long to wait for it.
+type profileBusyError struct {
+	kind       profileKind
+	retryAfter time.Duration
+}
+
+func (e *profileBusyError) Error() string {
+	if e.kind == cpuProfileKind {
+		return "a CPU profile is already in progress, see /debug/captures"
+	}
+	return "an execution trace is already in progress, see /debug/captures"
+}
+
+// limitProfile rejects requests whose duration parameters exceed the maximum profile
+// duration, and runs requests for CPU profiles and execution traces one at a time,
+// queueing them for up to the profile queue timeout. A queued request that is cancelled,
+// because its client disconnected, leaves the queue; the handlers stop capturing when
+// the request is cancelled.
+func (o *options) limitProfile(h http.Handler, params ...profileParam) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var kinds []profileKind
+		var longest time.Duration
+		for _, param := range params {
+			d := param.duration(r)
+			if o.maxProfileDuration > 0 && d > o.maxProfileDuration {
+				http.Error(w, fmt.Sprintf("%s=%s exceeds the maximum profile duration of %s", param.name, d, o.maxProfileDuration), http.StatusBadRequest)
+				return
+			}
+			if param.kind != "" && d > 0 {
+				kinds = append(kinds, param.kind)
+				longest = max(longest, d)
+			}
+		}
+		if len(kinds) == 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		capture, ok := o.acquireCapture(w, r, kinds, longest)
+		if !ok {
+			return
+		}
+		defer captures.release(capture)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// acquireCapture waits for the slots of kinds for a request, for up to the profile queue
+// timeout. When they stay busy it answers 429 Too Many Requests and returns false.
+func (o *options) acquireCapture(w http.ResponseWriter, r *http.Request, kinds []profileKind, d time.Duration) (*profileCapture, bool) {
+	capture, err := captures.acquire(r.Context(), r.URL.RequestURI(), r.RemoteAddr, kinds, d, o.profileQueueTimeout)
+	if err != nil {
+		if busy, ok := err.(*profileBusyError); ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(busy.retryAfter.Seconds()))))
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+		}
+		return nil, false
+	}
+	return capture, true
+}
+
+// acquire waits for the slots of kinds, in a fixed order so that captures needing both
+// cannot deadlock, until wait is over or ctx is done. request describes the capture on
+// /debug/captures, and remoteAddr is the client asking for it, if any.
+func (c *profileCaptures) acquire(ctx context.Context, request, remoteAddr string, kinds []profileKind, d, wait time.Duration) (*profileCapture, error) {
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+
+	c.mu.Lock()
+	c.nextID++
+	capture := &profileCapture{
+		ID:         c.nextID,
+		Request:    request,
+		RemoteAddr: remoteAddr,
+		Kinds:      kinds,
+		Duration:   Duration(d),
+		State:      "queued",
+		QueuedAt:   time.Now().UTC(),
+	}
+	c.captures[capture.ID] = capture
+	c.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for i, kind := range kinds {
+		select {
+		case c.slots[kind] <- struct{}{}:
+			continue
+		default:
+		}
+
+		var err error
+		if wait > 0 {
+			select {
+			case c.slots[kind] <- struct{}{}:
+				continue
+			case <-ctx.Done():
+				err = ctx.Err()
+			case <-timeout:
+			}
+		}
+		if err == nil {
+			err = &profileBusyError{kind: kind, retryAfter: c.remaining(kind)}
+		}
+		for _, acquired := range kinds[:i] {
+			<-c.slots[acquired]
+		}
+		c.mu.Lock()
+		delete(c.captures, capture.ID)
+		c.mu.Unlock()
+		return nil, err
+	}
+
+	c.mu.Lock()
+	startedAt := time.Now().UTC()
+	capture.State = "running"
+	capture.StartedAt = &startedAt
+	c.mu.Unlock()
+	return capture, nil
+}
+
+func (c *profileCaptures) release(capture *profileCapture) {
+	c.mu.Lock()
+	delete(c.captures, capture.ID)
+	c.mu.Unlock()
+	for _, kind := range capture.Kinds {
+		<-c.slots[kind]
+	}
+}
+
+// remaining estimates how long the running capture of kind takes to finish.
+func (c *profileCaptures) remaining(kind profileKind) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	remaining := time.Second
+	for _, capture := range c.captures {
+		if capture.StartedAt == nil {
+			continue
+		}
+		for _, k := range capture.Kinds {
+			if k == kind {
+				remaining = max(remaining, time.Until(capture.StartedAt.Add(time.Duration(capture.Duration))))
+			}
+		}
+	}
+	return remaining
+}
+
+// list returns the captures in the order they were requested.
+func (c *profileCaptures) list() []*profileCapture {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	list := make([]*profileCapture, 0, len(c.captures))
+	for _, capture := range c.captures {
+		copied := *capture
+		list = append(list, &copied)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// profileCapturesHandler serves /debug/captures, the CPU profiles and execution traces
+// that are running or waiting for their turn, with the limits of the server.
+func profileCapturesHandler(o *options) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, struct {
+			MaxProfileDuration  Duration          `json:"max_profile_duration"`
+			ProfileQueueTimeout Duration          `json:"profile_queue_timeout"`
+			Captures            []*profileCapture `json:"captures"`
+		}{Duration(o.maxProfileDuration), Duration(o.profileQueueTimeout), captures.list()})
+	}
+}
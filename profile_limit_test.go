@@ -0,0 +1,151 @@
+package debugserver_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	cf_debug_server "code.cloudfoundry.org/debugserver"
+	lager "code.cloudfoundry.org/lager/v3"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Profile limits", func() {
+	var (
+		sink   *lager.ReconfigurableSink
+		server *httptest.Server
+	)
+
+	BeforeEach(func() {
+		sink = lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.ERROR)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	start := func(opts ...cf_debug_server.Option) {
+		server = httptest.NewServer(cf_debug_server.Handler(sink, opts...))
+	}
+
+	get := func(ctx context.Context, path string) *http.Response {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+path, nil)
+		Expect(err).NotTo(HaveOccurred())
+		resp, err := http.DefaultClient.Do(req)
+		if ctx.Err() != nil {
+			return nil
+		}
+		Expect(err).NotTo(HaveOccurred())
+		return resp
+	}
+
+	type capture struct {
+		Request string   `json:"request"`
+		Kinds   []string `json:"kinds"`
+		State   string   `json:"state"`
+	}
+
+	inProgress := func() []capture {
+		resp := get(context.Background(), "/debug/captures")
+		defer resp.Body.Close()
+		var status struct {
+			Captures []capture `json:"captures"`
+		}
+		Expect(json.NewDecoder(resp.Body).Decode(&status)).To(Succeed())
+		return status.Captures
+	}
+
+	profileInBackground := func(ctx context.Context, path string) <-chan int {
+		status := make(chan int, 1)
+		go func() {
+			defer GinkgoRecover()
+			resp := get(ctx, path)
+			if resp == nil {
+				status <- 0
+				return
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			status <- resp.StatusCode
+		}()
+		return status
+	}
+
+	It("rejects profiles longer than the maximum, including the default of 30s", func() {
+		start(cf_debug_server.WithMaxProfileDuration(10 * time.Second))
+
+		resp := get(context.Background(), "/debug/pprof/profile")
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		Expect(string(body)).To(ContainSubstring("seconds=30s exceeds the maximum profile duration of 10s"))
+	})
+
+	It("checks a fractional CPU profile duration as the 30s default it runs for", func() {
+		start(cf_debug_server.WithMaxProfileDuration(time.Second))
+
+		resp := get(context.Background(), "/debug/pprof/profile?seconds=0.5")
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		Expect(string(body)).To(ContainSubstring("seconds=30s exceeds the maximum profile duration of 1s"))
+	})
+
+	It("checks durations sent in a form body", func() {
+		start(cf_debug_server.WithMaxProfileDuration(10 * time.Second))
+
+		resp, err := http.PostForm(server.URL+"/debug/pprof/profile", url.Values{"seconds": {"3600"}})
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+		Expect(string(body)).To(ContainSubstring("seconds=1h0m0s exceeds the maximum profile duration of 10s"))
+	})
+
+	It("answers a second CPU profile with 429 while one is in progress", func() {
+		start()
+		first := profileInBackground(context.Background(), "/debug/pprof/profile?seconds=2")
+		Eventually(inProgress).Should(ConsistOf(capture{Request: "/debug/pprof/profile?seconds=2", Kinds: []string{"cpu"}, State: "running"}))
+
+		resp := get(context.Background(), "/debug/bundle?seconds=1")
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+		Expect(resp.Header.Get("Retry-After")).NotTo(BeEmpty())
+
+		resp = get(context.Background(), "/debug/pprof/trace?seconds=1")
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+		Eventually(first, 5*time.Second).Should(Receive(Equal(http.StatusOK)))
+		Expect(inProgress()).To(BeEmpty())
+	})
+
+	It("queues a second CPU profile for up to the queue timeout", func() {
+		start(cf_debug_server.WithProfileQueueTimeout(5 * time.Second))
+		first := profileInBackground(context.Background(), "/debug/pprof/profile?seconds=1")
+		Eventually(inProgress).Should(HaveLen(1))
+
+		second := profileInBackground(context.Background(), "/debug/pprof/profile?seconds=1")
+		Eventually(inProgress).Should(ContainElement(HaveField("State", "queued")))
+
+		Eventually(first, 5*time.Second).Should(Receive(Equal(http.StatusOK)))
+		Eventually(second, 5*time.Second).Should(Receive(Equal(http.StatusOK)))
+	})
+
+	It("stops the capture when the client disconnects", func() {
+		start()
+		ctx, cancel := context.WithCancel(context.Background())
+		done := profileInBackground(ctx, "/debug/pprof/profile?seconds=20")
+		Eventually(inProgress).Should(HaveLen(1))
+
+		cancel()
+		Eventually(done).Should(Receive())
+		Eventually(inProgress).Should(BeEmpty())
+	})
+})
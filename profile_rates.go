@@ -0,0 +1,84 @@
+package debugserver
+
+import (
+	"net/http"
+	"runtime"
+	"sync"
+
+	lager "code.cloudfoundry.org/lager/v3"
+)
+
+// blockProfileRate is the rate last set through /block-profile-rate, since the runtime
+// cannot report it. Rates set with runtime.SetBlockProfileRate directly are not seen.
+var blockProfileRate struct {
+	sync.Mutex
+	rate int
+}
+
+func getBlockProfileRate() int {
+	blockProfileRate.Lock()
+	defer blockProfileRate.Unlock()
+
+	return blockProfileRate.rate
+}
+
+// setBlockProfileRate sets the block profile rate and returns the previous one.
+func setBlockProfileRate(rate int) int {
+	blockProfileRate.Lock()
+	defer blockProfileRate.Unlock()
+
+	previous := blockProfileRate.rate
+	runtime.SetBlockProfileRate(rate)
+	blockProfileRate.rate = rate
+	return previous
+}
+
+// blockProfileRateHandler reads the block profile rate on GET and sets it on POST or PUT,
+// responding with the previous value. Pass a value <= 0 to turn off block profiling.
+func blockProfileRateHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			writeCurrent(w, r, getBlockProfileRate())
+			return
+		}
+		if !isWriteMethod(r) {
+			writeControlError(w, r, methodNotAllowed(methodNotAllowedMsg))
+			return
+		}
+
+		rate, ok := readIntBody(w, r)
+		if !ok {
+			return
+		}
+
+		current := max(int(rate), 0)
+		previous := setBlockProfileRate(current)
+		addAuditParams(r, lager.Data{"previous": previous, "rate": rate})
+		writeChange(w, r, previous, current)
+	}
+}
+
+// mutexProfileFractionHandler reads the mutex profile fraction on GET and sets it on POST
+// or PUT, responding with the previous value. Pass a value <= 0 to turn off mutex profiling.
+func mutexProfileFractionHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			writeCurrent(w, r, runtime.SetMutexProfileFraction(-1))
+			return
+		}
+		if !isWriteMethod(r) {
+			writeControlError(w, r, methodNotAllowed(methodNotAllowedMsg))
+			return
+		}
+
+		rate, ok := readIntBody(w, r)
+		if !ok {
+			return
+		}
+
+		current := max(int(rate), 0)
+		previous := runtime.SetMutexProfileFraction(current)
+		addAuditParams(r, lager.Data{"previous": previous, "fraction": rate})
+		writeChange(w, r, previous, current)
+	}
+}
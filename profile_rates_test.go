@@ -0,0 +1,63 @@
+package debugserver_test
+
+import (
+	"io"
+	"net/http"
+	"runtime"
+
+	cf_debug_server "code.cloudfoundry.org/debugserver"
+	lager "code.cloudfoundry.org/lager/v3"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Profile rates", func() {
+	var handler http.Handler
+
+	BeforeEach(func() {
+		sink := lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.INFO)
+		handler = cf_debug_server.Handler(sink)
+	})
+
+	Describe("/block-profile-rate", func() {
+		BeforeEach(func() {
+			Expect(serveRequest(handler, http.MethodPost, "/block-profile-rate", "0").Code).To(Equal(http.StatusOK))
+			DeferCleanup(serveRequest, handler, http.MethodPost, "/block-profile-rate", "0")
+		})
+
+		It("sets the rate, responds with the previous one and reads it back", func() {
+			rec := serveRequest(handler, http.MethodPost, "/block-profile-rate", "1000")
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Body.String()).To(Equal("0\n"))
+			Expect(serveRequest(handler, http.MethodGet, "/block-profile-rate", "").Body.String()).To(Equal("1000\n"))
+
+			Expect(serveRequest(handler, http.MethodPut, "/block-profile-rate", "-5").Body.String()).To(Equal("1000\n"))
+			Expect(serveRequest(handler, http.MethodGet, "/block-profile-rate", "").Body.String()).To(Equal("0\n"))
+		})
+
+		It("rejects other methods", func() {
+			Expect(serveRequest(handler, http.MethodDelete, "/block-profile-rate", "1").Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(serveRequest(handler, http.MethodGet, "/block-profile-rate", "").Body.String()).To(Equal("0\n"))
+		})
+	})
+
+	Describe("/mutex-profile-fraction", func() {
+		BeforeEach(func() {
+			DeferCleanup(runtime.SetMutexProfileFraction, runtime.SetMutexProfileFraction(0))
+		})
+
+		It("sets the fraction, responds with the previous one and reads it back", func() {
+			rec := serveRequest(handler, http.MethodPost, "/mutex-profile-fraction", "5")
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Body.String()).To(Equal("0\n"))
+			Expect(serveRequest(handler, http.MethodGet, "/mutex-profile-fraction", "").Body.String()).To(Equal("5\n"))
+			Expect(runtime.SetMutexProfileFraction(-1)).To(Equal(5))
+		})
+
+		It("rejects other methods", func() {
+			Expect(serveRequest(handler, http.MethodPatch, "/mutex-profile-fraction", "5").Code).To(Equal(http.StatusMethodNotAllowed))
+			Expect(runtime.SetMutexProfileFraction(-1)).To(Equal(0))
+		})
+	})
+})
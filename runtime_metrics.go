@@ -0,0 +1,219 @@
+package debugserver
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+)
+
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// runtimeMetric is a single runtime/metrics sample together with its description.
+type runtimeMetric struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Kind        string              `json:"kind"`
+	Cumulative  bool                `json:"cumulative"`
+	Value       any                 `json:"value,omitempty"`
+	Histogram   *runtimeMetricsHist `json:"histogram,omitempty"`
+}
+
+// runtimeMetricsHist mirrors metrics.Float64Histogram: Buckets holds the
+// len(Counts)+1 bucket boundaries, which may start at -Inf and end at +Inf.
+type runtimeMetricsHist struct {
+	Counts  []uint64    `json:"counts"`
+	Buckets []jsonFloat `json:"buckets"`
+}
+
+// jsonFloat encodes infinities and NaN as strings, which plain JSON numbers cannot represent.
+type jsonFloat float64
+
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	v := float64(f)
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return json.Marshal(formatPrometheusFloat(v))
+	}
+	return json.Marshal(v)
+}
+
+// runtimeMetricsHandler serves every supported runtime/metrics sample as JSON or in the
+// Prometheus text exposition format. The format is chosen with ?format=json|prometheus,
+// or from the Accept header when no format is given.
+func runtimeMetricsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var prometheus bool
+		switch format := r.URL.Query().Get("format"); format {
+		case "json":
+		case "prometheus":
+			prometheus = true
+		case "":
+			accept := r.Header.Get("Accept")
+			prometheus = !wantsJSON(r) && (strings.Contains(accept, "text/plain") || strings.Contains(accept, "openmetrics"))
+		default:
+			http.Error(w, "invalid format "+strconv.Quote(format)+", use json or prometheus", http.StatusBadRequest)
+			return
+		}
+
+		samples := readRuntimeMetrics()
+		if prometheus {
+			w.Header().Set("Content-Type", prometheusContentType)
+			// #nosec G104 - ignore errors writing http response to avoid spamming logs during DoS
+			writePrometheusMetrics(w, samples)
+			return
+		}
+		writeJSON(w, http.StatusOK, struct {
+			Metrics []runtimeMetric `json:"metrics"`
+		}{samples})
+	}
+}
+
+// readRuntimeMetrics reads all metrics supported by the running Go version.
+func readRuntimeMetrics() []runtimeMetric {
+	descs := metrics.All()
+	samples := make([]metrics.Sample, len(descs))
+	for i := range descs {
+		samples[i].Name = descs[i].Name
+	}
+	metrics.Read(samples)
+
+	result := make([]runtimeMetric, 0, len(samples))
+	for i, sample := range samples {
+		m := runtimeMetric{
+			Name:        sample.Name,
+			Description: descs[i].Description,
+			Cumulative:  descs[i].Cumulative,
+		}
+		switch sample.Value.Kind() {
+		case metrics.KindUint64:
+			m.Kind = "uint64"
+			m.Value = sample.Value.Uint64()
+		case metrics.KindFloat64:
+			m.Kind = "float64"
+			m.Value = jsonFloat(sample.Value.Float64())
+		case metrics.KindFloat64Histogram:
+			h := sample.Value.Float64Histogram()
+			m.Kind = "histogram"
+			m.Histogram = &runtimeMetricsHist{Counts: h.Counts, Buckets: make([]jsonFloat, len(h.Buckets))}
+			for j, b := range h.Buckets {
+				m.Histogram.Buckets[j] = jsonFloat(b)
+			}
+		default:
+			// The metric is not supported by this Go version.
+			continue
+		}
+		result = append(result, m)
+	}
+	return result
+}
+
+// writePrometheusMetrics renders the samples in the Prometheus text exposition format.
+// Cumulative metrics become counters, histograms keep the runtime bucket boundaries, and
+// since the runtime does not track histogram sums, _sum is estimated from bucket midpoints.
+func writePrometheusMetrics(w io.Writer, samples []runtimeMetric) error {
+	bw := bufio.NewWriter(w)
+	for _, m := range samples {
+		name := prometheusName(m.Name)
+		help := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(m.Description)
+
+		switch {
+		case m.Histogram != nil:
+			fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+			writePrometheusHistogram(bw, name, m.Histogram)
+		case m.Cumulative:
+			name += "_total"
+			fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+			fmt.Fprintf(bw, "%s %s\n", name, prometheusValue(m.Value))
+		default:
+			fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+			fmt.Fprintf(bw, "%s %s\n", name, prometheusValue(m.Value))
+		}
+	}
+	return bw.Flush()
+}
+
+func writePrometheusHistogram(w io.Writer, name string, h *runtimeMetricsHist) {
+	var count uint64
+	var sum float64
+	for i, c := range h.Counts {
+		lower, upper := float64(h.Buckets[i]), float64(h.Buckets[i+1])
+		count += c
+		if c > 0 {
+			sum += float64(c) * bucketMidpoint(lower, upper)
+		}
+		if !math.IsInf(upper, 1) {
+			fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, formatPrometheusFloat(upper), count)
+		}
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, count)
+	fmt.Fprintf(w, "%s_sum %s\n", name, formatPrometheusFloat(sum))
+	fmt.Fprintf(w, "%s_count %d\n", name, count)
+}
+
+// bucketMidpoint estimates the value of samples in a bucket, falling back to the
+// finite boundary for the open-ended first and last buckets.
+func bucketMidpoint(lower, upper float64) float64 {
+	switch {
+	case math.IsInf(lower, -1) && math.IsInf(upper, 1):
+		return 0
+	case math.IsInf(lower, -1):
+		return upper
+	case math.IsInf(upper, 1):
+		return lower
+	default:
+		return lower + (upper-lower)/2
+	}
+}
+
+// prometheusName converts a runtime/metrics name such as /gc/heap/allocs:bytes
+// into a Prometheus metric name such as go_gc_heap_allocs_bytes.
+func prometheusName(name string) string {
+	path, unit, _ := strings.Cut(strings.TrimPrefix(name, "/"), ":")
+	var b strings.Builder
+	b.WriteString("go_")
+	for _, part := range []string{path, unit} {
+		if part == "" {
+			continue
+		}
+		if b.Len() > len("go_") {
+			b.WriteByte('_')
+		}
+		for _, c := range part {
+			if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' {
+				b.WriteRune(c)
+			} else {
+				b.WriteByte('_')
+			}
+		}
+	}
+	return b.String()
+}
+
+func prometheusValue(value any) string {
+	switch v := value.(type) {
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case jsonFloat:
+		return formatPrometheusFloat(float64(v))
+	default:
+		return "NaN"
+	}
+}
+
+func formatPrometheusFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,86 @@
+package debugserver_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	cf_debug_server "code.cloudfoundry.org/debugserver"
+	lager "code.cloudfoundry.org/lager/v3"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("/debug/metrics", func() {
+	var handler http.Handler
+
+	BeforeEach(func() {
+		sink := lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.INFO)
+		handler = cf_debug_server.Handler(sink)
+	})
+
+	type metric struct {
+		Name      string          `json:"name"`
+		Kind      string          `json:"kind"`
+		Value     json.RawMessage `json:"value"`
+		Histogram *struct {
+			Counts  []uint64      `json:"counts"`
+			Buckets []interface{} `json:"buckets"`
+		} `json:"histogram"`
+	}
+
+	It("returns the runtime metrics as JSON by default", func() {
+		rec := serveRequest(handler, http.MethodGet, "/debug/metrics", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Header().Get("Content-Type")).To(Equal("application/json"))
+
+		var body struct {
+			Metrics []metric `json:"metrics"`
+		}
+		Expect(json.Unmarshal(rec.Body.Bytes(), &body)).To(Succeed())
+
+		byName := map[string]metric{}
+		for _, m := range body.Metrics {
+			byName[m.Name] = m
+		}
+		Expect(byName).To(HaveKey("/gc/heap/goal:bytes"))
+		Expect(byName["/gc/heap/goal:bytes"].Kind).To(Equal("uint64"))
+		Expect(byName).To(HaveKey("/sched/goroutines:goroutines"))
+
+		Expect(byName).To(HaveKey("/sched/latencies:seconds"))
+		latencies := byName["/sched/latencies:seconds"]
+		Expect(latencies.Kind).To(Equal("histogram"))
+		Expect(latencies.Histogram.Buckets).To(HaveLen(len(latencies.Histogram.Counts) + 1))
+	})
+
+	It("returns the Prometheus exposition format when requested", func() {
+		rec := serveRequest(handler, http.MethodGet, "/debug/metrics?format=prometheus", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Header().Get("Content-Type")).To(HavePrefix("text/plain; version=0.0.4"))
+
+		body := rec.Body.String()
+		Expect(body).To(ContainSubstring("# TYPE go_gc_heap_goal_bytes gauge\n"))
+		Expect(body).To(ContainSubstring("# TYPE go_gc_heap_allocs_bytes_total counter\n"))
+		Expect(body).To(ContainSubstring("# TYPE go_sched_latencies_seconds histogram\n"))
+		Expect(body).To(ContainSubstring(`go_sched_latencies_seconds_bucket{le="+Inf"} `))
+		Expect(body).To(ContainSubstring("go_sched_latencies_seconds_count "))
+
+		for _, line := range strings.Split(strings.TrimSpace(body), "\n") {
+			if strings.HasPrefix(line, "#") {
+				continue
+			}
+			Expect(line).To(MatchRegexp(`^[a-zA-Z_][a-zA-Z0-9_]*(\{le="[^"]+"\})? \S+$`))
+		}
+	})
+
+	It("picks the Prometheus format from the Accept header", func() {
+		rec := serveRequest(handler, http.MethodGet, "/debug/metrics", "", "Accept", "text/plain;version=0.0.4;q=0.5,*/*;q=0.1")
+		Expect(rec.Header().Get("Content-Type")).To(HavePrefix("text/plain"))
+	})
+
+	It("rejects unknown formats", func() {
+		Expect(serveRequest(handler, http.MethodGet, "/debug/metrics?format=xml", "").Code).To(Equal(http.StatusBadRequest))
+	})
+})
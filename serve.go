@@ -0,0 +1,173 @@
+package debugserver
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tedsuo/ifrit"
+)
+
+const defaultShutdownTimeout = time.Minute
+
+// Timeouts bound the time the debug server spends on a connection. Zero values mean no
+// limit, except for Shutdown. Keep Write at zero, or longer than the longest profile that
+// will be requested, since /debug/pprof/profile and /debug/pprof/trace take ?seconds=n.
+type Timeouts struct {
+	ReadHeader time.Duration
+	Read       time.Duration
+	Write      time.Duration
+	Idle       time.Duration
+	// Shutdown is how long in-flight requests may take to finish when the server is
+	// stopped. Defaults to 1m.
+	Shutdown time.Duration
+}
+
+// New creates a debug server. The server is an ifrit.Runner that listens on the address
+// or listener from the options and runs the sidecars the options need, such as a flight
+// recorder, for as long as it runs. It can also be used as an http.Handler.
+func New(opts ...Option) (*Server, error) {
+	o := newOptions(opts)
+	if o.address != "" && o.listener != nil {
+		return nil, errors.New("debug server address and listener are mutually exclusive")
+	}
+	return newServer(o)
+}
+
+// WithAddress makes the server listen on host:port or unix:///path/to/socket.
+func WithAddress(address string) Option {
+	return func(o *options) {
+		o.address = address
+	}
+}
+
+// WithListener makes the server accept connections from listener, which it closes
+// when it stops. Use it instead of WithAddress, for example for socket activation.
+func WithListener(listener net.Listener) Option {
+	return func(o *options) {
+		o.listener = listener
+	}
+}
+
+// WithTLSConfig makes the server only accept TLS connections. See NewTLSConfig.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = tlsConfig
+	}
+}
+
+// WithUnixSocketOptions sets the mode and ownership of the socket file for unix:// addresses.
+func WithUnixSocketOptions(socketOpts UnixSocketOptions) Option {
+	return func(o *options) {
+		o.socketOpts = socketOpts
+	}
+}
+
+// WithLogController serves /log-level for the log controller. Without one, /log-level
+// is not served. A nil log controller keeps the one of WithLevelController, so that
+// Run(address, nil, WithLevelController(ctrl)) serves it.
+func WithLogController(zapCtrl zapLogLevelController) Option {
+	return func(o *options) {
+		if ctrl := levelControllerFor(zapCtrl); ctrl != nil {
+			o.logController = ctrl
+		}
+	}
+}
+
+// WithTimeouts sets the connection timeouts of the server.
+func WithTimeouts(timeouts Timeouts) Option {
+	return func(o *options) {
+		o.timeouts = timeouts
+	}
+}
+
+// WithEnabledEndpoints limits the built-in endpoints to the given paths, as listed on
+// the index page, for example "/debug/pprof/" and "/log-level". The index page and
+// endpoints added with WithEndpoint or Register are always served.
+func WithEnabledEndpoints(paths ...string) Option {
+	return func(o *options) {
+		o.enabled = map[string]bool{}
+		for _, path := range paths {
+			o.enabled[path] = true
+		}
+	}
+}
+
+// WithMiddleware wraps every endpoint in middleware, the first one outermost. The
+// middleware runs inside the audit log and network policy and outside the token auth.
+func WithMiddleware(middleware ...func(http.Handler) http.Handler) Option {
+	return func(o *options) {
+		o.middleware = append(o.middleware, middleware...)
+	}
+}
+
+// Run serves the debug endpoints until it is signalled, then waits up to the shutdown
+// timeout for in-flight requests.
+func (s *Server) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	return withSidecars(ifrit.RunFunc(s.serve), s.o.sidecars).Run(signals, ready)
+}
+
+func (s *Server) serve(signals <-chan os.Signal, ready chan<- struct{}) error {
+	listener, err := s.listen()
+	if err != nil {
+		return err
+	}
+	if err := s.o.checkStrictNetworkPolicy(listener.Addr()); err != nil {
+		// #nosec G104 - the policy error is more useful than an error closing the listener
+		listener.Close()
+		return err
+	}
+
+	timeouts := s.o.timeouts
+	server := &http.Server{
+		Handler:           s,
+		TLSConfig:         s.o.tlsConfig,
+		ReadHeaderTimeout: timeouts.ReadHeader,
+		ReadTimeout:       timeouts.Read,
+		WriteTimeout:      timeouts.Write,
+		IdleTimeout:       timeouts.Idle,
+	}
+	if s.o.tlsConfig != nil {
+		listener = tls.NewListener(listener, s.o.tlsConfig)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Serve(listener)
+	}()
+
+	close(ready)
+
+	select {
+	case err := <-errs:
+		return err
+	case <-signals:
+		shutdown := timeouts.Shutdown
+		if shutdown <= 0 {
+			shutdown = defaultShutdownTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), shutdown)
+		defer cancel()
+		// #nosec G104 - requests still running after the timeout are cut off, which is intended
+		server.Shutdown(ctx)
+		return nil
+	}
+}
+
+func (s *Server) listen() (net.Listener, error) {
+	switch {
+	case s.o.listener != nil:
+		return s.o.listener, nil
+	case IsUnixSocketAddress(s.o.address):
+		return listenUnix(strings.TrimPrefix(s.o.address, UnixSocketScheme), s.o.socketOpts)
+	case s.o.address != "":
+		return net.Listen("tcp", s.o.address)
+	default:
+		return nil, errors.New("debug server needs an address or a listener to run")
+	}
+}
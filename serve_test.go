@@ -0,0 +1,175 @@
+package debugserver_test
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	cf_debug_server "code.cloudfoundry.org/debugserver"
+	lager "code.cloudfoundry.org/lager/v3"
+	"github.com/tedsuo/ifrit"
+	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("New", func() {
+	var (
+		sink    *lager.ReconfigurableSink
+		process ifrit.Process
+	)
+
+	BeforeEach(func() {
+		sink = lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.INFO)
+		process = nil
+	})
+
+	AfterEach(func() {
+		if process != nil {
+			ginkgomon.Interrupt(process)
+		}
+	})
+
+	get := func(url string) (int, string) {
+		resp, err := http.Get(url)
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		Expect(err).NotTo(HaveOccurred())
+		return resp.StatusCode, string(body)
+	}
+
+	It("runs the server on the address", func() {
+		server, err := cf_debug_server.New(
+			cf_debug_server.WithAddress(address),
+			cf_debug_server.WithLogController(sink),
+		)
+		Expect(err).NotTo(HaveOccurred())
+		process = ginkgomon.Invoke(server)
+
+		status, body := get(fmt.Sprintf("http://%s/log-level", address))
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(body).To(Equal("info\n"))
+	})
+
+	It("runs the server on a listener", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		server, err := cf_debug_server.New(cf_debug_server.WithListener(listener))
+		Expect(err).NotTo(HaveOccurred())
+		process = ginkgomon.Invoke(server)
+
+		status, _ := get(fmt.Sprintf("http://%s/debug/pprof/cmdline", listener.Addr()))
+		Expect(status).To(Equal(http.StatusOK))
+	})
+
+	It("does not serve /log-level without a log controller", func() {
+		server, err := cf_debug_server.New(cf_debug_server.WithAddress(address))
+		Expect(err).NotTo(HaveOccurred())
+		process = ginkgomon.Invoke(server)
+
+		status, _ := get(fmt.Sprintf("http://%s/log-level", address))
+		Expect(status).To(Equal(http.StatusNotFound))
+	})
+
+	It("serves only the enabled endpoints", func() {
+		server, err := cf_debug_server.New(
+			cf_debug_server.WithAddress(address),
+			cf_debug_server.WithLogController(sink),
+			cf_debug_server.WithEnabledEndpoints("/debug/pprof/", "/debug/pprof/cmdline"),
+		)
+		Expect(err).NotTo(HaveOccurred())
+		process = ginkgomon.Invoke(server)
+
+		status, _ := get(fmt.Sprintf("http://%s/debug/pprof/cmdline", address))
+		Expect(status).To(Equal(http.StatusOK))
+		status, _ = get(fmt.Sprintf("http://%s/log-level", address))
+		Expect(status).To(Equal(http.StatusNotFound))
+		status, _ = get(fmt.Sprintf("http://%s/gc-percent", address))
+		Expect(status).To(Equal(http.StatusNotFound))
+
+		var paths []string
+		for _, endpoint := range server.Endpoints() {
+			paths = append(paths, endpoint.Path)
+		}
+		Expect(paths).To(ConsistOf("/{$}", "/debug/pprof/", "/debug/pprof/cmdline"))
+	})
+
+	It("wraps the endpoints in the middleware, first one outermost", func() {
+		tag := func(name string) func(http.Handler) http.Handler {
+			return func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Add("X-Middleware", name)
+					next.ServeHTTP(w, r)
+				})
+			}
+		}
+		server, err := cf_debug_server.New(
+			cf_debug_server.WithAddress(address),
+			cf_debug_server.WithMiddleware(tag("first"), tag("second")),
+		)
+		Expect(err).NotTo(HaveOccurred())
+		process = ginkgomon.Invoke(server)
+
+		resp, err := http.Get(fmt.Sprintf("http://%s/debug/pprof/cmdline", address))
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.Header.Values("X-Middleware")).To(Equal([]string{"first", "second"}))
+	})
+
+	It("applies the timeouts", func() {
+		server, err := cf_debug_server.New(
+			cf_debug_server.WithAddress(address),
+			cf_debug_server.WithTimeouts(cf_debug_server.Timeouts{ReadHeader: 100 * time.Millisecond}),
+		)
+		Expect(err).NotTo(HaveOccurred())
+		process = ginkgomon.Invoke(server)
+
+		conn, err := net.Dial("tcp", address)
+		Expect(err).NotTo(HaveOccurred())
+		defer conn.Close()
+		Expect(conn.SetReadDeadline(time.Now().Add(5 * time.Second))).To(Succeed())
+		start := time.Now()
+		_, err = conn.Read(make([]byte, 1))
+		Expect(err).To(MatchError(io.EOF))
+		Expect(time.Since(start)).To(BeNumerically("<", 2*time.Second))
+	})
+
+	DescribeTable("rejects invalid options",
+		func(expected string, opts ...cf_debug_server.Option) {
+			_, err := cf_debug_server.New(opts...)
+			Expect(err).To(MatchError(ContainSubstring(expected)))
+		},
+		Entry("address and listener", "mutually exclusive",
+			cf_debug_server.WithAddress("127.0.0.1:0"), cf_debug_server.WithListener(&net.TCPListener{})),
+		Entry("unknown endpoint", "unknown debug endpoint /debug/nope",
+			cf_debug_server.WithEnabledEndpoints("/debug/nope")),
+		Entry("duplicate endpoint", "already registered",
+			cf_debug_server.WithEndpoint("/gc", "", cf_debug_server.ControlEndpoint, http.NotFoundHandler())),
+	)
+
+	It("fails to run without an address", func() {
+		server, err := cf_debug_server.New()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(<-ifrit.Invoke(server).Wait()).To(MatchError(ContainSubstring("needs an address or a listener")))
+	})
+
+	Describe("Runner", func() {
+		It("fails to run with invalid options", func() {
+			runner := cf_debug_server.Runner(address, sink, cf_debug_server.WithEnabledEndpoints("/debug/nope"))
+			Expect(<-ifrit.Invoke(runner).Wait()).To(MatchError(ContainSubstring("unknown debug endpoint")))
+		})
+
+		It("takes the address over one given as an option", func() {
+			process = ginkgomon.Invoke(cf_debug_server.Runner(address, sink, cf_debug_server.WithAddress("127.0.0.1:1")))
+
+			status, body := get(fmt.Sprintf("http://%s/log-level", address))
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(strings.TrimSpace(body)).To(Equal("info"))
+		})
+	})
+})
@@ -2,23 +2,99 @@ package debugserver
 
 import (
 	"flag"
-	"io"
+	"fmt"
 	"net/http"
 	"net/http/pprof"
-	"runtime"
-	"strconv"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	lager "code.cloudfoundry.org/lager/v3"
 	"github.com/tedsuo/ifrit"
-	"github.com/tedsuo/ifrit/http_server"
 )
 
 const (
-	DebugFlag = "debugAddr"
+	DebugFlag            = "debugAddr"
+	DebugSocketModeFlag  = "debugSocketMode"
+	DebugSocketOwnerFlag = "debugSocketOwner"
+	DebugSocketGroupFlag = "debugSocketGroup"
 )
 
 type DebugServerConfig struct {
-	DebugAddress string `json:"debug_address"`
+	DebugAddress    string `json:"debug_address"`
+	DebugCertFile   string `json:"debug_cert_file,omitempty"`
+	DebugKeyFile    string `json:"debug_key_file,omitempty"`
+	DebugCACertFile string `json:"debug_ca_cert_file,omitempty"`
+
+	DebugSocketMode  string `json:"debug_socket_mode,omitempty"`
+	DebugSocketOwner string `json:"debug_socket_owner,omitempty"`
+	DebugSocketGroup string `json:"debug_socket_group,omitempty"`
+
+	DebugAuthTokenFile        string `json:"debug_auth_token_file,omitempty"`
+	DebugAuthRequiredForReads bool   `json:"debug_auth_required_for_reads,omitempty"`
+
+	// DebugAllowedNetworks are the CIDRs or addresses of the clients that may use the debug server.
+	DebugAllowedNetworks []string `json:"debug_allowed_networks,omitempty"`
+	// DebugTrustedProxies are the CIDRs or addresses of proxies whose X-Forwarded-For header is used.
+	DebugTrustedProxies []string `json:"debug_trusted_proxies,omitempty"`
+	// DebugStrictNetworkPolicy refuses non-loopback addresses unless auth or TLS is configured.
+	DebugStrictNetworkPolicy bool `json:"debug_strict_network_policy,omitempty"`
+
+	DebugFlightRecorderEnabled  bool     `json:"debug_flight_recorder_enabled,omitempty"`
+	DebugFlightRecorderWindow   Duration `json:"debug_flight_recorder_window,omitempty"`
+	DebugFlightRecorderMaxBytes uint64   `json:"debug_flight_recorder_max_bytes,omitempty"`
+
+	// DebugEnabledEndpoints limits the built-in endpoints to the listed paths. Empty enables all of them.
+	DebugEnabledEndpoints   []string `json:"debug_enabled_endpoints,omitempty"`
+	DebugMaxProfileDuration Duration `json:"debug_max_profile_duration,omitempty"`
+	// DebugProfileQueueTimeout is how long a CPU profile or trace request waits for the one in progress.
+	DebugProfileQueueTimeout Duration `json:"debug_profile_queue_timeout,omitempty"`
+
+	DebugReadHeaderTimeout Duration `json:"debug_read_header_timeout,omitempty"`
+	DebugReadTimeout       Duration `json:"debug_read_timeout,omitempty"`
+	DebugWriteTimeout      Duration `json:"debug_write_timeout,omitempty"`
+	DebugIdleTimeout       Duration `json:"debug_idle_timeout,omitempty"`
+	DebugShutdownTimeout   Duration `json:"debug_shutdown_timeout,omitempty"`
+}
+
+// HandlerOptions returns the options described by the config. When the flight recorder
+// is enabled, the options must be passed to Runner (or one of its variants) for it to record.
+func (c DebugServerConfig) HandlerOptions() []Option {
+	var opts []Option
+	if c.DebugFlightRecorderEnabled {
+		opts = append(opts, WithFlightRecorder(NewFlightRecorder(FlightRecorderConfig{
+			Window:   time.Duration(c.DebugFlightRecorderWindow),
+			MaxBytes: c.DebugFlightRecorderMaxBytes,
+		})))
+	}
+	if c.DebugAuthTokenFile != "" {
+		scope := AuthControlEndpoints
+		if c.DebugAuthRequiredForReads {
+			scope = AuthAllEndpoints
+		}
+		opts = append(opts, WithTokenAuth(c.DebugAuthTokenFile, scope))
+	}
+	if len(c.DebugAllowedNetworks) > 0 {
+		opts = append(opts, WithAllowedNetworks(c.DebugAllowedNetworks...))
+	}
+	if len(c.DebugTrustedProxies) > 0 {
+		opts = append(opts, WithTrustedProxies(c.DebugTrustedProxies...))
+	}
+	if c.DebugStrictNetworkPolicy {
+		opts = append(opts, WithStrictNetworkPolicy())
+	}
+	if len(c.DebugEnabledEndpoints) > 0 {
+		opts = append(opts, WithEnabledEndpoints(c.DebugEnabledEndpoints...))
+	}
+	if c.DebugMaxProfileDuration > 0 {
+		opts = append(opts, WithMaxProfileDuration(time.Duration(c.DebugMaxProfileDuration)))
+	}
+	if c.DebugProfileQueueTimeout > 0 {
+		opts = append(opts, WithProfileQueueTimeout(time.Duration(c.DebugProfileQueueTimeout)))
+	}
+	return opts
 }
 
 type ReconfigurableSinkInterface interface {
@@ -29,7 +105,22 @@ func AddFlags(flags *flag.FlagSet) {
 	flags.String(
 		DebugFlag,
 		"",
-		"host:port for serving pprof debugging info",
+		"host:port or unix:///path/to/socket for serving pprof debugging info",
+	)
+	flags.String(
+		DebugSocketModeFlag,
+		"",
+		"octal permissions of the debug server unix socket (default 0600)",
+	)
+	flags.String(
+		DebugSocketOwnerFlag,
+		"",
+		"user name or id owning the debug server unix socket",
+	)
+	flags.String(
+		DebugSocketGroupFlag,
+		"",
+		"group name or id owning the debug server unix socket",
 	)
 }
 
@@ -41,16 +132,36 @@ func DebugAddress(flags *flag.FlagSet) string {
 	return dbgFlag.Value.String()
 }
 
+// DebugUnixSocketOptions returns the unix socket settings registered by AddFlags.
+func DebugUnixSocketOptions(flags *flag.FlagSet) (UnixSocketOptions, error) {
+	lookup := func(name string) string {
+		f := flags.Lookup(name)
+		if f == nil {
+			return ""
+		}
+		return f.Value.String()
+	}
+	mode, err := parseSocketMode(lookup(DebugSocketModeFlag))
+	if err != nil {
+		return UnixSocketOptions{}, err
+	}
+	return UnixSocketOptions{
+		Mode:  mode,
+		Owner: lookup(DebugSocketOwnerFlag),
+		Group: lookup(DebugSocketGroupFlag),
+	}, nil
+}
+
 // Run starts the debug server with the provided address and log controller.
-// Run() -> runProcess() -> Runner() -> http_server.New() -> Handler()
-func Run(address string, zapCtrl zapLogLevelController) (ifrit.Process, error) {
-	return runProcess(address, &LagerAdapter{zapCtrl})
+// Run() -> runProcess() -> Runner() -> New()
+func Run(address string, zapCtrl zapLogLevelController, opts ...Option) (ifrit.Process, error) {
+	return runProcess(Runner(address, zapCtrl, opts...))
 }
 
-// runProcess starts the debug server and returns the process.
-// It invokes the Runner with the provided address and log controller.
-func runProcess(address string, zapCtrl zapLogLevelController) (ifrit.Process, error) {
-	p := ifrit.Invoke(Runner(address, zapCtrl))
+// runProcess starts the debug server runner and returns the process
+// once it is ready, or the error it exited with.
+func runProcess(runner ifrit.Runner) (ifrit.Process, error) {
+	p := ifrit.Invoke(runner)
 	select {
 	case <-p.Ready():
 	case err := <-p.Wait():
@@ -60,94 +171,301 @@ func runProcess(address string, zapCtrl zapLogLevelController) (ifrit.Process, e
 }
 
 // Runner creates an ifrit.Runner for the debug server with the provided address and log controller.
-func Runner(address string, zapCtrl zapLogLevelController) ifrit.Runner {
-	return http_server.New(address, Handler(zapCtrl))
-}
-
-func Handler(zapCtrl zapLogLevelController) http.Handler {
-	mux := http.NewServeMux()
-	mux.Handle("/debug/pprof/", http.HandlerFunc(pprof.Index))
-	mux.Handle("/debug/pprof/trace", http.HandlerFunc(pprof.Trace))
-	mux.Handle("/debug/pprof/cmdline", http.HandlerFunc(pprof.Cmdline))
-	mux.Handle("/debug/pprof/profile", http.HandlerFunc(pprof.Profile))
-	mux.Handle("/debug/pprof/symbol", http.HandlerFunc(pprof.Symbol))
-	mux.Handle("/log-level", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Read the log level from the request body.
-		level, err := io.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, "Failed to read body", http.StatusBadRequest)
-			return
-		}
-		// Validate the log level request.
-		var normalizedLevel string
-		if normalizedLevel, err = validateAndNormalize(w, r, level); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
+// The address is either host:port or unix:///path/to/socket; sockets are created with DefaultUnixSocketMode.
+// It is a shorthand for New with WithAddress and WithLogController.
+func Runner(address string, zapCtrl zapLogLevelController, opts ...Option) ifrit.Runner {
+	return newRunner(opts, WithAddress(address), WithLogController(zapCtrl))
+}
+
+// newRunner creates the server for the positional constructors, which take precedence
+// over opts. If the options are invalid, the runner fails with the error when run.
+func newRunner(opts []Option, positional ...Option) ifrit.Runner {
+	s, err := New(append(append([]Option{}, opts...), positional...)...)
+	if err != nil {
+		return ifrit.RunFunc(func(<-chan os.Signal, chan<- struct{}) error {
+			return err
+		})
+	}
+	return s
+}
+
+// Handler returns the debug server endpoints for the provided log controller.
+// Like http.ServeMux with an invalid pattern, it panics if the options are invalid;
+// use NewServer to get the error instead, or to add endpoints of your own.
+func Handler(zapCtrl zapLogLevelController, opts ...Option) http.Handler {
+	s, err := NewServer(zapCtrl, opts...)
+	if err != nil {
+		panic("debugserver: " + err.Error())
+	}
+	return s
+}
+
+// EndpointKind tells the debug server whether an endpoint only reports on the process
+// or changes it, which decides whether AuthControlEndpoints protects it.
+type EndpointKind int
+
+const (
+	// ReadEndpoint only reports on the process, like the pprof endpoints.
+	ReadEndpoint EndpointKind = iota
+	// ControlEndpoint changes the behavior of the process, like /log-level.
+	ControlEndpoint
+)
+
+func (k EndpointKind) String() string {
+	if k == ControlEndpoint {
+		return "control"
+	}
+	return "read"
+}
+
+func (k EndpointKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// Endpoint describes an endpoint served by the debug server, as listed on its index page.
+type Endpoint struct {
+	Path        string       `json:"path"`
+	Description string       `json:"description"`
+	Kind        EndpointKind `json:"kind"`
+}
+
+// Server serves the debug endpoints. Endpoints added with Register sit next to the
+// built-in ones: they are listed on the index page at / and get the same token auth
+// and audit logging.
+type Server struct {
+	o       *options
+	mux     *http.ServeMux
+	handler http.Handler
+
+	mu        sync.Mutex
+	endpoints map[string]Endpoint
+}
+
+// NewServer creates the debug server for the provided log controller, for use as an
+// http.Handler. It is New with WithLogController.
+func NewServer(zapCtrl zapLogLevelController, opts ...Option) (*Server, error) {
+	return New(append(append([]Option{}, opts...), WithLogController(zapCtrl))...)
+}
+
+// WithEndpoint registers an endpoint like Server.Register does, for servers created
+// by Runner and its variants.
+func WithEndpoint(path, description string, kind EndpointKind, handler http.Handler) Option {
+	return func(o *options) {
+		o.endpoints = append(o.endpoints, registeredEndpoint{
+			Endpoint: Endpoint{Path: path, Description: description, Kind: kind},
+			handler:  handler,
+		})
+	}
+}
+
+type registeredEndpoint struct {
+	Endpoint
+	handler http.Handler
+}
+
+// Register adds an endpoint to the debug server. The path is a pattern as understood
+// by http.ServeMux, such as /debug/cache or /debug/connections/. Endpoints can be
+// registered while the server is running.
+func (s *Server) Register(path, description string, kind EndpointKind, handler http.Handler) error {
+	if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("debug endpoint path %q must start with /", path)
+	}
+	if handler == nil {
+		return fmt.Errorf("debug endpoint %s has no handler", path)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.endpoints[path]; ok {
+		return fmt.Errorf("debug endpoint %s is already registered", path)
+	}
+	if err := s.handle(path, kind, handler); err != nil {
+		return err
+	}
+	s.endpoints[path] = Endpoint{Path: path, Description: description, Kind: kind}
+	return nil
+}
+
+// Endpoints lists the registered endpoints, sorted by path.
+func (s *Server) Endpoints() []Endpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	endpoints := make([]Endpoint, 0, len(s.endpoints))
+	for _, endpoint := range s.endpoints {
+		endpoints = append(endpoints, endpoint)
+	}
+	// Sort by the path as listed on the index page, so that / comes first.
+	sort.Slice(endpoints, func(i, j int) bool {
+		return strings.TrimSuffix(endpoints[i].Path, "{$}") < strings.TrimSuffix(endpoints[j].Path, "{$}")
+	})
+	return endpoints
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// handle adds a handler to the mux behind the auth matching its kind.
+// http.ServeMux panics on invalid or conflicting patterns, which is turned into an error.
+func (s *Server) handle(path string, kind EndpointKind, handler http.Handler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid debug endpoint %s: %v", path, r)
 		}
-		// Convert the log level to lager.LogLevel.
-		if normalizedLevel == "warn" {
-			// Note that zapcore.WarnLevel is not directly supported by lager.
-			// And lager does not have a separate WARN level, it uses INFO for warnings.
-			// So to set the minimum level to "warn" we send an Invalid log level of 99,
-			// which hits the default case in the SetMinLevel method.
-			// This is a workaround to ensure that the log level is set correctly.
-			zapCtrl.SetMinLevel(lager.LogLevel(99))
-		} else {
-			lagerLogLevel, err := lager.LogLevelFromString(normalizedLevel)
-			if err != nil {
-				http.Error(w, "Invalid log level: "+err.Error(), http.StatusBadRequest)
-				return
-			}
-			zapCtrl.SetMinLevel(lagerLogLevel)
+	}()
+	if kind == ControlEndpoint {
+		s.mux.Handle(path, s.o.control(handler))
+	} else {
+		s.mux.Handle(path, s.o.read(handler))
+	}
+	return nil
+}
+
+func newServer(o *options) (*Server, error) {
+	s := &Server{
+		o:         o,
+		mux:       http.NewServeMux(),
+		endpoints: map[string]Endpoint{},
+	}
+	if err := o.checkLogControllerNames(); err != nil {
+		return nil, err
+	}
+	policy, err := newNetworkPolicy(o)
+	if err != nil {
+		return nil, err
+	}
+	var handler http.Handler = s.mux
+	for i := len(o.middleware) - 1; i >= 0; i-- {
+		handler = o.middleware[i](handler)
+	}
+	s.handler = o.audit(policy.wrap(handler))
+
+	if err := s.Register("/{$}", "this index of the debug endpoints", ReadEndpoint, indexHandler(s)); err != nil {
+		return nil, err
+	}
+
+	for path := range o.enabled {
+		if !isBuiltinEndpoint(o, path) {
+			return nil, fmt.Errorf("unknown debug endpoint %s", path)
 		}
-		// Respond with a success message.
-		w.WriteHeader(http.StatusOK)
-		w.Header().Set("Content-Type", "text/plain")
-		w.Write([]byte("/log-level was invoked with Level: " + normalizedLevel + "\n"))
-		if normalizedLevel == "fatal" {
-			w.Write([]byte("Note: Fatal logs are reported as error logs in the Gorouter logs.\n"))
+	}
+	for _, endpoint := range builtinEndpoints(o) {
+		if endpoint.handler == nil || (o.enabled != nil && !o.enabled[endpoint.Path]) {
+			continue
 		}
-	}))
-	mux.Handle("/block-profile-rate", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		_rate, err := io.ReadAll(r.Body)
-		if err != nil {
-			return
+		if err := s.Register(endpoint.Path, endpoint.Description, endpoint.Kind, endpoint.handler); err != nil {
+			return nil, err
 		}
+	}
 
-		rate, err := strconv.Atoi(string(_rate))
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			// #nosec G104 - ignore errors writing http response to avoid spamming logs during  DoS
-			w.Write([]byte(err.Error()))
-			return
+	for _, endpoint := range o.endpoints {
+		if err := s.Register(endpoint.Path, endpoint.Description, endpoint.Kind, endpoint.handler); err != nil {
+			return nil, err
 		}
+	}
+	return s, nil
+}
 
-		if rate <= 0 {
-			runtime.SetBlockProfileRate(0)
-		} else {
-			runtime.SetBlockProfileRate(rate)
+// builtinEndpointList describes the built-in endpoints. It does not depend on the
+// options, so that DebugServerConfig.Validate and newServer check the paths given to
+// WithEnabledEndpoints against the same list.
+var builtinEndpointList = []Endpoint{
+	{"/debug/pprof/", "index of the pprof profiles, and the profiles themselves", ReadEndpoint},
+	{"/debug/pprof/trace", "execution trace, ?seconds=n", ReadEndpoint},
+	{"/debug/pprof/cmdline", "command line of the process", ReadEndpoint},
+	{"/debug/pprof/profile", "CPU profile, ?seconds=n", ReadEndpoint},
+	{"/debug/pprof/symbol", "function names of program counters", ReadEndpoint},
+	{"/debug/captures", "CPU profiles and execution traces in progress", ReadEndpoint},
+	{"/debug/metrics", "runtime/metrics samples as JSON or Prometheus text", ReadEndpoint},
+	{"/debug/bundle", "archive of all profiles for an incident", ReadEndpoint},
+	{"/debug/buildinfo", "build and version information", ReadEndpoint},
+	{"/debug/goroutines", "goroutines grouped by stack, filterable", ReadEndpoint},
+	{"/debug/goroutines/snapshots", "list the goroutine snapshots", ReadEndpoint},
+	{"/debug/goroutines/snapshots/", "take, show or delete a named goroutine snapshot", ControlEndpoint},
+	{"/debug/goroutines/diff", "stacks that grew since a snapshot, ?snapshot=name", ReadEndpoint},
+	{"/debug/goroutines/leaks", "stacks suspected of leaking goroutines", ReadEndpoint},
+	{"/debug/flight-recorder", "execution trace of the last few seconds, ?seconds=n", ReadEndpoint},
+	{"/log-level", "get or set the log level", ControlEndpoint},
+	{"/log-levels", "list or set the levels of all log controllers", ControlEndpoint},
+	{"/block-profile-rate", "get or set the block profile rate", ControlEndpoint},
+	{"/mutex-profile-fraction", "get or set the mutex profile fraction", ControlEndpoint},
+	{"/gc-percent", "get or set GOGC", ControlEndpoint},
+	{"/memory-limit", "get or set the soft memory limit", ControlEndpoint},
+	{"/gc", "run a garbage collection", ControlEndpoint},
+	{"/free-os-memory", "return freed memory to the operating system", ControlEndpoint},
+}
+
+// isBuiltinEndpoint reports whether path is one of builtinEndpointList or the
+// /log-level/NAME of a named log controller of the options.
+func isBuiltinEndpoint(o *options, path string) bool {
+	for _, endpoint := range builtinEndpointList {
+		if endpoint.Path == path {
+			return true
 		}
-	}))
-	mux.Handle("/mutex-profile-fraction", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		_rate, err := io.ReadAll(r.Body)
-		if err != nil {
-			return
+	}
+	for _, ctrl := range o.namedLogControllers {
+		if path == "/log-level/"+ctrl.name {
+			return true
 		}
+	}
+	return false
+}
 
-		rate, err := strconv.Atoi(string(_rate))
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			// #nosec G104 - ignore errors writing http response to avoid spamming logs during  DoS
-			w.Write([]byte(err.Error()))
-			return
+// builtinEndpoints pairs builtinEndpointList with the handlers for the options, and adds
+// /log-level/NAME for each named log controller. Optional endpoints have a nil handler
+// when they are not configured.
+func builtinEndpoints(o *options) []registeredEndpoint {
+	var logLevel, logLevels, leaks, flightRecorder http.Handler
+	targets := logLevelTargets(o)
+	if len(targets) > 0 {
+		logLevels = logLevelsHandler(targets)
+		if targets[0].name == "" {
+			logLevel = logLevelHandler(targets[0].override)
 		}
-
-		if rate <= 0 {
-			runtime.SetMutexProfileFraction(0)
-		} else {
-			runtime.SetMutexProfileFraction(rate)
+	}
+	if o.leakDetector != nil {
+		leaks = o.leakDetector
+	}
+	if o.flightRecorder != nil {
+		flightRecorder = o.flightRecorder
+	}
+	snapshots := newGoroutineSnapshots()
+	handlers := map[string]http.Handler{
+		"/debug/pprof/":                o.limitProfile(http.HandlerFunc(pprof.Index), profileParam{name: "seconds", whole: true}),
+		"/debug/pprof/trace":           o.limitProfile(http.HandlerFunc(pprof.Trace), profileParam{name: "seconds", kind: executionTraceKind, def: time.Second}),
+		"/debug/pprof/cmdline":         http.HandlerFunc(pprof.Cmdline),
+		"/debug/pprof/profile":         o.limitProfile(http.HandlerFunc(pprof.Profile), profileParam{name: "seconds", kind: cpuProfileKind, def: 30 * time.Second, whole: true}),
+		"/debug/pprof/symbol":          http.HandlerFunc(pprof.Symbol),
+		"/debug/captures":              profileCapturesHandler(o),
+		"/debug/metrics":               runtimeMetricsHandler(),
+		"/debug/bundle":                o.limitProfile(bundleHandler(o, targets), profileParam{name: "seconds", whole: true}, profileParam{name: "trace", whole: true}),
+		"/debug/buildinfo":             buildInfoHandler(o.versionFields),
+		"/debug/goroutines":            goroutinesHandler(),
+		"/debug/goroutines/snapshots":  snapshots.snapshotsHandler(),
+		"/debug/goroutines/snapshots/": snapshots.snapshotsHandler(),
+		"/debug/goroutines/diff":       snapshots.diffHandler(),
+		"/debug/goroutines/leaks":      leaks,
+		"/debug/flight-recorder":       flightRecorder,
+		"/log-level":                   logLevel,
+		"/log-levels":                  logLevels,
+		"/block-profile-rate":          blockProfileRateHandler(),
+		"/mutex-profile-fraction":      mutexProfileFractionHandler(),
+		"/gc-percent":                  gcPercentHandler(),
+		"/memory-limit":                memoryLimitHandler(),
+		"/gc":                          gcHandler(),
+		"/free-os-memory":              freeOSMemoryHandler(),
+	}
+	endpoints := make([]registeredEndpoint, 0, len(builtinEndpointList)+len(targets))
+	for _, endpoint := range builtinEndpointList {
+		endpoints = append(endpoints, registeredEndpoint{endpoint, handlers[endpoint.Path]})
+	}
+	for _, target := range targets {
+		if target.name != "" {
+			endpoints = append(endpoints, registeredEndpoint{
+				Endpoint{target.path(), "get or set the log level of " + target.name, ControlEndpoint},
+				logLevelHandler(target.override),
+			})
 		}
-	}))
-
-	return mux
+	}
+	return endpoints
 }
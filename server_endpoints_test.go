@@ -0,0 +1,158 @@
+package debugserver_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	cf_debug_server "code.cloudfoundry.org/debugserver"
+	lager "code.cloudfoundry.org/lager/v3"
+	"github.com/tedsuo/ifrit"
+	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Server", func() {
+	var (
+		sink   *lager.ReconfigurableSink
+		server *cf_debug_server.Server
+		cache  http.HandlerFunc
+	)
+
+	BeforeEach(func() {
+		sink = lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.INFO)
+		var err error
+		server, err = cf_debug_server.NewServer(sink)
+		Expect(err).NotTo(HaveOccurred())
+		cache = func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "3 entries")
+		}
+	})
+
+	It("serves registered endpoints next to the built-in ones", func() {
+		Expect(server.Register("/debug/cache", "entries of the route cache", cf_debug_server.ReadEndpoint, cache)).To(Succeed())
+
+		rec := serveRequest(server, http.MethodGet, "/debug/cache", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(Equal("3 entries"))
+		Expect(serveRequest(server, http.MethodGet, "/debug/pprof/cmdline", "").Code).To(Equal(http.StatusOK))
+
+		Expect(server.Endpoints()).To(ContainElements(
+			cf_debug_server.Endpoint{Path: "/debug/cache", Description: "entries of the route cache", Kind: cf_debug_server.ReadEndpoint},
+			HaveField("Path", "/log-level"),
+		))
+	})
+
+	It("refuses invalid and duplicate paths", func() {
+		Expect(server.Register("debug/cache", "", cf_debug_server.ReadEndpoint, cache)).To(MatchError(ContainSubstring("must start with /")))
+		Expect(server.Register("/debug/cache", "", cf_debug_server.ReadEndpoint, nil)).To(MatchError(ContainSubstring("no handler")))
+		Expect(server.Register("/log-level", "", cf_debug_server.ControlEndpoint, cache)).To(MatchError(ContainSubstring("already registered")))
+		Expect(server.Register("/debug/{a}/{a}", "", cf_debug_server.ReadEndpoint, cache)).To(MatchError(ContainSubstring("invalid debug endpoint")))
+		Expect(server.Endpoints()).NotTo(ContainElement(HaveField("Path", "/debug/{a}/{a}")))
+	})
+
+	It("returns invalid options as an error", func() {
+		_, err := cf_debug_server.NewServer(sink, cf_debug_server.WithEnabledEndpoints("/debug/nope"))
+		Expect(err).To(MatchError("unknown debug endpoint /debug/nope"))
+	})
+
+	It("panics when Handler gets invalid options", func() {
+		Expect(func() {
+			cf_debug_server.Handler(sink, cf_debug_server.WithEnabledEndpoints("/debug/nope"))
+		}).To(PanicWith("debugserver: unknown debug endpoint /debug/nope"))
+	})
+
+	Describe("the index page", func() {
+		BeforeEach(func() {
+			Expect(server.Register("/debug/cache", "entries of the route cache", cf_debug_server.ReadEndpoint, cache)).To(Succeed())
+		})
+
+		It("lists the endpoints as text", func() {
+			rec := serveRequest(server, http.MethodGet, "/", "")
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Body.String()).To(MatchRegexp(`(?m)^/debug/cache +read +entries of the route cache$`))
+			Expect(rec.Body.String()).To(MatchRegexp(`(?m)^/log-level +control +get or set the log level$`))
+			Expect(rec.Body.String()).To(MatchRegexp(`^/ +read +this index`))
+		})
+
+		It("lists the endpoints as JSON", func() {
+			rec := serveRequest(server, http.MethodGet, "/", "", "Accept", "application/json")
+			var index struct {
+				Endpoints []struct {
+					Path        string `json:"path"`
+					Description string `json:"description"`
+					Kind        string `json:"kind"`
+				} `json:"endpoints"`
+			}
+			Expect(json.Unmarshal(rec.Body.Bytes(), &index)).To(Succeed())
+			Expect(index.Endpoints).To(ContainElement(HaveField("Path", "/debug/cache")))
+			Expect(index.Endpoints).To(ContainElement(And(HaveField("Path", "/gc"), HaveField("Kind", "control"))))
+		})
+
+		It("links the endpoints for browsers", func() {
+			rec := serveRequest(server, http.MethodGet, "/", "", "Accept", "text/html,application/xhtml+xml")
+			Expect(rec.Header().Get("Content-Type")).To(Equal("text/html; charset=utf-8"))
+			Expect(rec.Body.String()).To(ContainSubstring(`<a href="/debug/cache">/debug/cache</a>`))
+		})
+
+		It("does not catch unknown paths", func() {
+			Expect(serveRequest(server, http.MethodGet, "/unknown", "").Code).To(Equal(http.StatusNotFound))
+		})
+	})
+
+	Context("with token auth and an audit log", func() {
+		var logs *bytes.Buffer
+
+		BeforeEach(func() {
+			tokenFile := filepath.Join(GinkgoT().TempDir(), "token")
+			Expect(os.WriteFile(tokenFile, []byte("s3cret"), 0600)).To(Succeed())
+			logs = &bytes.Buffer{}
+			logger := lager.NewLogger("test")
+			logger.RegisterSink(lager.NewWriterSink(logs, lager.DEBUG))
+
+			var err error
+			server, err = cf_debug_server.NewServer(sink,
+				cf_debug_server.WithTokenAuth(tokenFile, cf_debug_server.AuthControlEndpoints),
+				cf_debug_server.WithLogger(logger),
+			)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(server.Register("/debug/cache", "", cf_debug_server.ReadEndpoint, cache)).To(Succeed())
+			Expect(server.Register("/debug/cache/flush", "", cf_debug_server.ControlEndpoint, cache)).To(Succeed())
+		})
+
+		It("applies them to registered endpoints by kind", func() {
+			Expect(serveRequest(server, http.MethodGet, "/debug/cache", "").Code).To(Equal(http.StatusOK))
+			Expect(serveRequest(server, http.MethodPost, "/debug/cache/flush", "").Code).To(Equal(http.StatusUnauthorized))
+
+			Expect(logs.String()).To(ContainSubstring(`"endpoint":"/debug/cache"`))
+			Expect(logs.String()).To(ContainSubstring(`"endpoint":"/debug/cache/flush"`))
+		})
+	})
+
+	Context("when run with WithEndpoint", func() {
+		var process ifrit.Process
+
+		AfterEach(func() {
+			ginkgomon.Interrupt(process)
+		})
+
+		It("serves the endpoint", func() {
+			process = ginkgomon.Invoke(cf_debug_server.Runner(address, sink,
+				cf_debug_server.WithEndpoint("/debug/cache", "entries of the route cache", cf_debug_server.ReadEndpoint, cache),
+			))
+
+			resp, err := http.Get(fmt.Sprintf("http://%s/debug/cache", address))
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(body)).To(Equal("3 entries"))
+		})
+	})
+})
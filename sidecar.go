@@ -0,0 +1,60 @@
+package debugserver
+
+import (
+	"os"
+
+	"github.com/tedsuo/ifrit"
+)
+
+// withSidecars returns a runner that starts the sidecars before main and stops
+// them after main exits. It returns the first error of main or a sidecar.
+func withSidecars(main ifrit.Runner, sidecars []ifrit.Runner) ifrit.Runner {
+	if len(sidecars) == 0 {
+		return main
+	}
+	return ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
+		var started []ifrit.Process
+		stop := func() error {
+			var firstErr error
+			for i := len(started) - 1; i >= 0; i-- {
+				started[i].Signal(os.Interrupt)
+				if err := <-started[i].Wait(); err != nil && firstErr == nil {
+					firstErr = err
+				}
+			}
+			return firstErr
+		}
+
+		for _, sidecar := range sidecars {
+			p := ifrit.Background(sidecar)
+			select {
+			case <-p.Ready():
+				started = append(started, p)
+			case err := <-p.Wait():
+				stop()
+				return err
+			}
+		}
+
+		mainProcess := ifrit.Background(main)
+		select {
+		case <-mainProcess.Ready():
+		case err := <-mainProcess.Wait():
+			stop()
+			return err
+		}
+		close(ready)
+
+		for {
+			select {
+			case sig := <-signals:
+				mainProcess.Signal(sig)
+			case err := <-mainProcess.Wait():
+				if stopErr := stop(); err == nil {
+					err = stopErr
+				}
+				return err
+			}
+		}
+	})
+}
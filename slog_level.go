@@ -0,0 +1,53 @@
+package debugserver
+
+import (
+	"log/slog"
+
+	lager "code.cloudfoundry.org/lager/v3"
+)
+
+// SlogLevelController controls the levels of log/slog handlers through their
+// slog.LevelVar. Levels map to slog levels one to one, so custom levels such as
+// slog.LevelDebug-4 are set with "debug-4" or "slog:-8" and read back as "debug-4".
+//
+// It can be passed to Run and the other functions that take a lager sink, so that
+// slog-based programs do not need lager to use the debug server:
+//
+//	levelVar := &slog.LevelVar{}
+//	logger := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: levelVar}))
+//	process, err := debugserver.Run(address, debugserver.NewSlogLevelController(levelVar))
+type SlogLevelController struct {
+	levelVars []*slog.LevelVar
+}
+
+// NewSlogLevelController controls one or more slog.LevelVars, which are all set to the
+// same level, for example those of a JSON handler and a text handler.
+func NewSlogLevelController(levelVars ...*slog.LevelVar) *SlogLevelController {
+	return &SlogLevelController{levelVars: levelVars}
+}
+
+// SetLevel sets every LevelVar to the level.
+func (c *SlogLevelController) SetLevel(level Level) {
+	for _, levelVar := range c.levelVars {
+		levelVar.Set(slog.Level(level))
+	}
+}
+
+// GetLevel returns the lowest level of the LevelVars, which is the most verbose one
+// when they were changed elsewhere.
+func (c *SlogLevelController) GetLevel() Level {
+	if len(c.levelVars) == 0 {
+		return LevelInfo
+	}
+	lowest := c.levelVars[0].Level()
+	for _, levelVar := range c.levelVars[1:] {
+		lowest = min(lowest, levelVar.Level())
+	}
+	return Level(lowest)
+}
+
+// SetMinLevel sets every LevelVar to a lager level, so that the controller can be
+// passed to Run.
+func (c *SlogLevelController) SetMinLevel(level lager.LogLevel) {
+	c.SetLevel(levelFromLager(level))
+}
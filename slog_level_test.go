@@ -0,0 +1,67 @@
+package debugserver_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+
+	cf_debug_server "code.cloudfoundry.org/debugserver"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SlogLevelController", func() {
+	var (
+		jsonLevel, textLevel *slog.LevelVar
+		handler              http.Handler
+	)
+
+	BeforeEach(func() {
+		jsonLevel = &slog.LevelVar{}
+		textLevel = &slog.LevelVar{}
+		handler = cf_debug_server.Handler(cf_debug_server.NewSlogLevelController(jsonLevel, textLevel))
+	})
+
+	DescribeTable("sets every LevelVar and reads the level back",
+		func(input string, level slog.Level, name string) {
+			Expect(serveRequest(handler, http.MethodPost, "/log-level", input).Code).To(Equal(http.StatusOK))
+			Expect(jsonLevel.Level()).To(Equal(level))
+			Expect(textLevel.Level()).To(Equal(level))
+			Expect(serveRequest(handler, http.MethodGet, "/log-level", "").Body.String()).To(Equal(name + "\n"))
+		},
+		Entry("debug", "d", slog.LevelDebug, "debug"),
+		Entry("warn", "WARN", slog.LevelWarn, "warn"),
+		Entry("error", "3", slog.LevelError, "error"),
+		Entry("fatal, which slog calls ERROR+4", "ERROR+4", slog.LevelError+4, "fatal"),
+		Entry("a custom level by name", "DEBUG-4", slog.LevelDebug-4, "debug-4"),
+		Entry("a custom level by number", "slog:-8", slog.LevelDebug-4, "debug-4"),
+		Entry("a custom level between named ones", "INFO+2", slog.LevelInfo+2, "info+2"),
+	)
+
+	It("filters the records of slog handlers", func() {
+		logs := &bytes.Buffer{}
+		logger := slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{Level: jsonLevel}))
+
+		Expect(serveRequest(handler, http.MethodPost, "/log-level", "warn").Code).To(Equal(http.StatusOK))
+		logger.Info("hidden")
+		logger.Warn("shown")
+		Expect(logs.String()).NotTo(ContainSubstring("hidden"))
+		Expect(logs.String()).To(ContainSubstring("shown"))
+	})
+
+	It("reports the most verbose level when the LevelVars were changed elsewhere", func() {
+		textLevel.Set(slog.LevelDebug)
+		Expect(serveRequest(handler, http.MethodGet, "/log-level", "").Body.String()).To(Equal("debug\n"))
+	})
+
+	It("restores the level of every LevelVar after a timed override", func() {
+		jsonLevel.Set(slog.LevelError)
+		textLevel.Set(slog.LevelError)
+		Expect(serveRequest(handler, http.MethodPost, "/log-level", "debug for=100ms").Code).To(Equal(http.StatusOK))
+		Expect(textLevel.Level()).To(Equal(slog.LevelDebug))
+
+		Eventually(jsonLevel.Level).Should(Equal(slog.LevelError))
+		Eventually(textLevel.Level).Should(Equal(slog.LevelError))
+	})
+})
@@ -0,0 +1,146 @@
+package debugserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tedsuo/ifrit"
+)
+
+// RunTLS starts the debug server over TLS with the provided address, log controller and TLS config.
+// RunTLS() -> runProcess() -> TLSRunner() -> New()
+func RunTLS(address string, zapCtrl zapLogLevelController, tlsConfig *tls.Config, opts ...Option) (ifrit.Process, error) {
+	return runProcess(TLSRunner(address, zapCtrl, tlsConfig, opts...))
+}
+
+// TLSRunner creates an ifrit.Runner for the debug server that only accepts TLS connections.
+// Use NewTLSConfig to build a config that requires client certificates signed by a given CA.
+func TLSRunner(address string, zapCtrl zapLogLevelController, tlsConfig *tls.Config, opts ...Option) ifrit.Runner {
+	return newRunner(opts, WithAddress(address), WithLogController(zapCtrl), WithTLSConfig(tlsConfig))
+}
+
+// NewTLSConfig returns a TLS config for the debug server that presents the certificate
+// in certFile/keyFile and requires clients to present a certificate signed by the CA in caCertFile.
+// The files are checked on every handshake and reloaded when they change on disk,
+// so certificates can be rotated without restarting the process.
+func NewTLSConfig(certFile, keyFile, caCertFile string) (*tls.Config, error) {
+	if certFile == "" || keyFile == "" || caCertFile == "" {
+		return nil, errors.New("cert file, key file and CA cert file are all required for TLS")
+	}
+
+	reloader := &certReloader{
+		certFile:   certFile,
+		keyFile:    keyFile,
+		caCertFile: caCertFile,
+	}
+	if err := reloader.reload(); err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ClientAuth:         tls.RequireAndVerifyClientCert,
+		GetConfigForClient: reloader.getConfigForClient,
+	}, nil
+}
+
+// TLSConfig builds the debug server TLS config from the configured cert, key and CA files.
+// It returns a nil config when no TLS files are configured.
+func (c DebugServerConfig) TLSConfig() (*tls.Config, error) {
+	if c.DebugCertFile == "" && c.DebugKeyFile == "" && c.DebugCACertFile == "" {
+		return nil, nil
+	}
+	return NewTLSConfig(c.DebugCertFile, c.DebugKeyFile, c.DebugCACertFile)
+}
+
+// certReloader keeps the server certificate and client CA pool in sync with the files on disk.
+type certReloader struct {
+	certFile   string
+	keyFile    string
+	caCertFile string
+
+	mu        sync.Mutex
+	modTimes  [3]time.Time
+	cert      tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+// getConfigForClient is used as tls.Config.GetConfigForClient so that every handshake
+// sees the most recent certificate and CA pool.
+func (r *certReloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.changed() {
+		// Keep serving the previous certificate if the new files are incomplete or invalid,
+		// for example while they are still being written.
+		_ = r.loadLocked()
+	}
+
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{r.cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    r.clientCAs,
+	}, nil
+}
+
+func (r *certReloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.loadLocked()
+}
+
+// changed reports whether any of the files have a different modification time
+// than when they were last loaded.
+func (r *certReloader) changed() bool {
+	for i, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return false
+		}
+		if !info.ModTime().Equal(r.modTimes[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *certReloader) loadLocked() error {
+	var modTimes [3]time.Time
+	for i, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[i] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load debug server key pair: %w", err)
+	}
+
+	caCert, err := os.ReadFile(r.caCertFile)
+	if err != nil {
+		return fmt.Errorf("failed to read debug server CA cert: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caCert) {
+		return fmt.Errorf("no valid certificates found in %s", r.caCertFile)
+	}
+
+	r.cert = cert
+	r.clientCAs = clientCAs
+	r.modTimes = modTimes
+	return nil
+}
+
+func (r *certReloader) files() [3]string {
+	return [3]string{r.certFile, r.keyFile, r.caCertFile}
+}
@@ -0,0 +1,226 @@
+package debugserver_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	cf_debug_server "code.cloudfoundry.org/debugserver"
+	lager "code.cloudfoundry.org/lager/v3"
+	"github.com/tedsuo/ifrit"
+	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TLS", func() {
+	var (
+		sink    *lager.ReconfigurableSink
+		certDir string
+		ca      *testCA
+
+		certFile, keyFile, caFile string
+
+		process ifrit.Process
+	)
+
+	BeforeEach(func() {
+		sink = lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.INFO)
+		certDir = GinkgoT().TempDir()
+
+		ca = newTestCA()
+		certFile = filepath.Join(certDir, "server.crt")
+		keyFile = filepath.Join(certDir, "server.key")
+		caFile = filepath.Join(certDir, "ca.crt")
+		ca.writeLeaf(certFile, keyFile)
+		Expect(os.WriteFile(caFile, ca.certPEM, 0600)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		ginkgomon.Interrupt(process)
+	})
+
+	Describe("NewTLSConfig", func() {
+		It("requires all files", func() {
+			_, err := cf_debug_server.NewTLSConfig(certFile, keyFile, "")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("fails when the CA file has no certificates", func() {
+			Expect(os.WriteFile(caFile, []byte("garbage"), 0600)).To(Succeed())
+			_, err := cf_debug_server.NewTLSConfig(certFile, keyFile, caFile)
+			Expect(err).To(MatchError(ContainSubstring("no valid certificates")))
+		})
+	})
+
+	Describe("DebugServerConfig.TLSConfig", func() {
+		It("returns nil when no TLS files are configured", func() {
+			tlsConfig, err := cf_debug_server.DebugServerConfig{DebugAddress: address}.TLSConfig()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tlsConfig).To(BeNil())
+		})
+
+		It("builds a mutual TLS config from the configured files", func() {
+			tlsConfig, err := cf_debug_server.DebugServerConfig{
+				DebugAddress:    address,
+				DebugCertFile:   certFile,
+				DebugKeyFile:    keyFile,
+				DebugCACertFile: caFile,
+			}.TLSConfig()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tlsConfig.ClientAuth).To(Equal(tls.RequireAndVerifyClientCert))
+		})
+	})
+
+	Describe("RunTLS", func() {
+		BeforeEach(func() {
+			tlsConfig, err := cf_debug_server.NewTLSConfig(certFile, keyFile, caFile)
+			Expect(err).NotTo(HaveOccurred())
+
+			process, err = cf_debug_server.RunTLS(address, sink, tlsConfig)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("serves debug information to clients with a trusted certificate", func() {
+			resp, err := ca.client().Get(fmt.Sprintf("https://%s/debug/pprof/goroutine", address))
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		})
+
+		It("sets the log level over TLS", func() {
+			resp, err := ca.client().Post(fmt.Sprintf("https://%s/log-level", address), "text/plain", strings.NewReader("debug"))
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			Expect(sink.GetMinLevel()).To(Equal(lager.DEBUG))
+		})
+
+		It("rejects clients without a certificate", func() {
+			client := &http.Client{Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: ca.pool()},
+			}}
+			_, err := client.Get(fmt.Sprintf("https://%s/debug/pprof/goroutine", address))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("rejects clients with a certificate from another CA", func() {
+			_, err := newTestCA().client().Get(fmt.Sprintf("https://%s/debug/pprof/goroutine", address))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("reloads the certificates when the files change", func() {
+			newCA := newTestCA()
+			newCA.writeLeaf(certFile, keyFile)
+			Expect(os.WriteFile(caFile, newCA.certPEM, 0600)).To(Succeed())
+			future := time.Now().Add(time.Minute)
+			for _, file := range []string{certFile, keyFile, caFile} {
+				Expect(os.Chtimes(file, future, future)).To(Succeed())
+			}
+
+			_, err := ca.client().Get(fmt.Sprintf("https://%s/debug/pprof/goroutine", address))
+			Expect(err).To(HaveOccurred())
+
+			resp, err := newCA.client().Get(fmt.Sprintf("https://%s/debug/pprof/goroutine", address))
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		})
+	})
+})
+
+type testCA struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+}
+
+func newTestCA() *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "debugserver-test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).NotTo(HaveOccurred())
+
+	return &testCA{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// leaf returns a PEM encoded certificate and key signed by the CA
+// that is valid for both server and client authentication on 127.0.0.1.
+func (ca *testCA) leaf() ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	Expect(err).NotTo(HaveOccurred())
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	Expect(err).NotTo(HaveOccurred())
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func (ca *testCA) writeLeaf(certFile, keyFile string) {
+	certPEM, keyPEM := ca.leaf()
+	Expect(os.WriteFile(certFile, certPEM, 0600)).To(Succeed())
+	Expect(os.WriteFile(keyFile, keyPEM, 0600)).To(Succeed())
+}
+
+func (ca *testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	Expect(pool.AppendCertsFromPEM(ca.certPEM)).To(BeTrue())
+	return pool
+}
+
+// client returns an HTTP client that trusts the CA and presents a certificate signed by it.
+func (ca *testCA) client() *http.Client {
+	certPEM, keyPEM := ca.leaf()
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	Expect(err).NotTo(HaveOccurred())
+
+	return &http.Client{Transport: &http.Transport{
+		DisableKeepAlives: true,
+		TLSClientConfig: &tls.Config{
+			RootCAs:      ca.pool(),
+			Certificates: []tls.Certificate{cert},
+		},
+	}}
+}
@@ -0,0 +1,164 @@
+package debugserver
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tedsuo/ifrit"
+)
+
+const (
+	// UnixSocketScheme prefixes debug addresses that refer to a unix domain socket,
+	// for example unix:///var/vcap/data/gorouter/debug.sock.
+	UnixSocketScheme = "unix://"
+
+	// DefaultUnixSocketMode is applied to the socket file when no mode is configured.
+	DefaultUnixSocketMode os.FileMode = 0600
+)
+
+// UnixSocketOptions control the socket file created for unix:// debug addresses.
+type UnixSocketOptions struct {
+	// Mode is applied to the socket file. Zero means DefaultUnixSocketMode.
+	Mode os.FileMode
+	// Owner and Group are user and group names or numeric ids.
+	// Empty values leave the ownership unchanged.
+	Owner string
+	Group string
+}
+
+// UnixSocketRunner creates an ifrit.Runner for the debug server listening on the unix socket
+// in address (unix:///path/to/sock). A stale socket left behind by a previous process is removed
+// on start, and the socket is removed again on shutdown.
+func UnixSocketRunner(address string, zapCtrl zapLogLevelController, socketOpts UnixSocketOptions, opts ...Option) ifrit.Runner {
+	return newRunner(opts, WithAddress(address), WithLogController(zapCtrl), WithUnixSocketOptions(socketOpts))
+}
+
+// IsUnixSocketAddress reports whether address refers to a unix domain socket.
+func IsUnixSocketAddress(address string) bool {
+	return strings.HasPrefix(address, UnixSocketScheme)
+}
+
+// UnixSocketOptions parses the socket file settings from the config.
+func (c DebugServerConfig) UnixSocketOptions() (UnixSocketOptions, error) {
+	mode, err := parseSocketMode(c.DebugSocketMode)
+	if err != nil {
+		return UnixSocketOptions{}, err
+	}
+	return UnixSocketOptions{
+		Mode:  mode,
+		Owner: c.DebugSocketOwner,
+		Group: c.DebugSocketGroup,
+	}, nil
+}
+
+// listenUnix listens on the socket at path, replacing a stale socket, and applies the
+// socket options. The returned listener removes the socket file when it is closed.
+func listenUnix(path string, opts UnixSocketOptions) (net.Listener, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.apply(path); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// removeStaleSocket removes a socket file that no process is listening on anymore.
+// It refuses to remove anything that is not a socket or that still accepts connections.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("debug socket path %s exists and is not a socket", path)
+	}
+
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("debug socket %s is already in use", path)
+	}
+	return os.Remove(path)
+}
+
+func (o UnixSocketOptions) apply(path string) error {
+	mode := o.Mode
+	if mode == 0 {
+		mode = DefaultUnixSocketMode
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		return err
+	}
+
+	if o.Owner == "" && o.Group == "" {
+		return nil
+	}
+	uid, gid := -1, -1
+	if o.Owner != "" {
+		u, err := lookupID(o.Owner, func(name string) (string, error) {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		})
+		if err != nil {
+			return fmt.Errorf("invalid debug socket owner %q: %w", o.Owner, err)
+		}
+		uid = u
+	}
+	if o.Group != "" {
+		g, err := lookupID(o.Group, func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		})
+		if err != nil {
+			return fmt.Errorf("invalid debug socket group %q: %w", o.Group, err)
+		}
+		gid = g
+	}
+	return os.Chown(path, uid, gid)
+}
+
+// lookupID accepts either a numeric id or a name resolved with lookup.
+func lookupID(nameOrID string, lookup func(string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(nameOrID); err == nil {
+		return id, nil
+	}
+	id, err := lookup(nameOrID)
+	if err != nil {
+		return -1, err
+	}
+	return strconv.Atoi(id)
+}
+
+// parseSocketMode parses an octal permission string such as "0660".
+// An empty string yields zero, which means DefaultUnixSocketMode.
+func parseSocketMode(mode string) (os.FileMode, error) {
+	if mode == "" {
+		return 0, nil
+	}
+	m, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || m > 0777 {
+		return 0, fmt.Errorf("invalid debug socket mode %q, expected octal permissions such as 0600", mode)
+	}
+	return os.FileMode(m), nil
+}
@@ -0,0 +1,180 @@
+package debugserver_test
+
+import (
+	"context"
+	"flag"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	cf_debug_server "code.cloudfoundry.org/debugserver"
+	lager "code.cloudfoundry.org/lager/v3"
+	"github.com/tedsuo/ifrit"
+	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Unix socket", func() {
+	var (
+		sink       *lager.ReconfigurableSink
+		socketPath string
+		socketAddr string
+
+		process ifrit.Process
+	)
+
+	BeforeEach(func() {
+		sink = lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.INFO)
+		socketPath = filepath.Join(GinkgoT().TempDir(), "debug.sock")
+		socketAddr = cf_debug_server.UnixSocketScheme + socketPath
+	})
+
+	AfterEach(func() {
+		ginkgomon.Interrupt(process)
+	})
+
+	unixClient := func() *http.Client {
+		return &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socketPath)
+			},
+		}}
+	}
+
+	Describe("Run", func() {
+		It("serves debug information on the socket", func() {
+			var err error
+			process, err = cf_debug_server.Run(socketAddr, sink)
+			Expect(err).NotTo(HaveOccurred())
+
+			resp, err := unixClient().Get("http://unix/debug/pprof/goroutine")
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		})
+
+		It("creates the socket with the default mode", func() {
+			var err error
+			process, err = cf_debug_server.Run(socketAddr, sink)
+			Expect(err).NotTo(HaveOccurred())
+
+			info, err := os.Stat(socketPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(cf_debug_server.DefaultUnixSocketMode))
+		})
+
+		It("removes the socket on shutdown", func() {
+			var err error
+			process, err = cf_debug_server.Run(socketAddr, sink)
+			Expect(err).NotTo(HaveOccurred())
+
+			ginkgomon.Interrupt(process)
+			process = nil
+			Expect(socketPath).NotTo(BeAnExistingFile())
+		})
+
+		Context("when a stale socket exists", func() {
+			BeforeEach(func() {
+				listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: socketPath, Net: "unix"})
+				Expect(err).NotTo(HaveOccurred())
+				listener.SetUnlinkOnClose(false)
+				Expect(listener.Close()).To(Succeed())
+				Expect(socketPath).To(BeAnExistingFile())
+			})
+
+			It("replaces it", func() {
+				var err error
+				process, err = cf_debug_server.Run(socketAddr, sink)
+				Expect(err).NotTo(HaveOccurred())
+
+				resp, err := unixClient().Get("http://unix/debug/pprof/goroutine")
+				Expect(err).NotTo(HaveOccurred())
+				defer resp.Body.Close()
+			})
+		})
+
+		Context("when another process is listening on the socket", func() {
+			var listener net.Listener
+
+			BeforeEach(func() {
+				var err error
+				listener, err = net.Listen("unix", socketPath)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				listener.Close()
+			})
+
+			It("returns an error and leaves the socket alone", func() {
+				var err error
+				process, err = cf_debug_server.Run(socketAddr, sink)
+				Expect(err).To(MatchError(ContainSubstring("already in use")))
+				Expect(socketPath).To(BeAnExistingFile())
+			})
+		})
+
+		Context("when the path is a regular file", func() {
+			BeforeEach(func() {
+				Expect(os.WriteFile(socketPath, []byte("data"), 0600)).To(Succeed())
+			})
+
+			It("returns an error", func() {
+				var err error
+				process, err = cf_debug_server.Run(socketAddr, sink)
+				Expect(err).To(MatchError(ContainSubstring("is not a socket")))
+			})
+		})
+	})
+
+	Describe("UnixSocketRunner", func() {
+		It("applies the configured mode", func() {
+			process = ginkgomon.Invoke(cf_debug_server.UnixSocketRunner(socketAddr, sink, cf_debug_server.UnixSocketOptions{Mode: 0660}))
+
+			info, err := os.Stat(socketPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0660)))
+		})
+
+		It("fails to start when the owner does not exist", func() {
+			process = ifrit.Invoke(cf_debug_server.UnixSocketRunner(socketAddr, sink, cf_debug_server.UnixSocketOptions{Owner: "no-such-debugserver-user"}))
+			Eventually(process.Wait()).Should(Receive(MatchError(ContainSubstring("invalid debug socket owner"))))
+			process = nil
+		})
+	})
+
+	Describe("DebugUnixSocketOptions", func() {
+		var flags *flag.FlagSet
+
+		BeforeEach(func() {
+			flags = flag.NewFlagSet("test", flag.ContinueOnError)
+			cf_debug_server.AddFlags(flags)
+		})
+
+		It("returns the parsed socket settings", func() {
+			Expect(flags.Parse([]string{
+				"-debugAddr", socketAddr,
+				"-debugSocketMode", "0660",
+				"-debugSocketOwner", "vcap",
+				"-debugSocketGroup", "1000",
+			})).To(Succeed())
+
+			Expect(cf_debug_server.DebugAddress(flags)).To(Equal(socketAddr))
+			opts, err := cf_debug_server.DebugUnixSocketOptions(flags)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(opts).To(Equal(cf_debug_server.UnixSocketOptions{Mode: 0660, Owner: "vcap", Group: "1000"}))
+		})
+
+		It("rejects a mode that is not octal permissions", func() {
+			Expect(flags.Parse([]string{"-debugSocketMode", "rw-rw----"})).To(Succeed())
+
+			_, err := cf_debug_server.DebugUnixSocketOptions(flags)
+			Expect(err).To(MatchError(ContainSubstring("invalid debug socket mode")))
+		})
+	})
+})
@@ -0,0 +1,327 @@
+package debugserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"time"
+
+	lager "code.cloudfoundry.org/lager/v3"
+)
+
+const (
+	defaultWatchdogInterval    = 10 * time.Second
+	defaultWatchdogCooldown    = 5 * time.Minute
+	defaultWatchdogMaxCaptures = 10
+	defaultCPUProfileDuration  = 10 * time.Second
+
+	watchdogCapturePrefix = "watchdog-"
+)
+
+// WatchdogConfig configures when a Watchdog captures profiles and where it keeps them.
+// Thresholds left at zero are not checked. Other zero values use the documented defaults.
+type WatchdogConfig struct {
+	// Dir is where captures are written, one directory per capture. It is required.
+	Dir string
+
+	// Interval between checks of the runtime stats. Defaults to 10s.
+	Interval time.Duration
+
+	// HeapInuseBytes triggers a capture when the heap spans in use exceed it.
+	HeapInuseBytes uint64
+	// Goroutines triggers a capture when the number of goroutines exceeds it.
+	Goroutines int
+	// CPUPercent triggers a capture when the process CPU usage, as a percentage of
+	// one core like top reports it, stays above it for CPUSustainedFor.
+	CPUPercent      float64
+	CPUSustainedFor time.Duration
+
+	// CPUProfileDuration is how long the CPU profile of a capture runs. Defaults to 10s.
+	CPUProfileDuration time.Duration
+	// Cooldown is the minimum time between two captures. Defaults to 5m.
+	Cooldown time.Duration
+	// MaxCaptures is how many captures are kept in Dir; older ones are removed. Defaults to 10.
+	MaxCaptures int
+}
+
+// Watchdog polls runtime stats and, when a threshold is crossed, writes heap, goroutine
+// and CPU profiles to disk so there is evidence after the fact.
+//
+// A Watchdog is an ifrit.Runner. Passing it to Runner with WithWatchdog runs it
+// alongside the debug server.
+type Watchdog struct {
+	logger lager.Logger
+	cfg    WatchdogConfig
+
+	lastCapture time.Time
+	lastCPU     time.Duration
+	lastCPUAt   time.Time
+	cpuHigh     time.Time
+}
+
+// NewWatchdog creates a watchdog that logs its captures and failures to logger.
+func NewWatchdog(logger lager.Logger, cfg WatchdogConfig) *Watchdog {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultWatchdogInterval
+	}
+	if cfg.CPUProfileDuration <= 0 {
+		cfg.CPUProfileDuration = defaultCPUProfileDuration
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = defaultWatchdogCooldown
+	}
+	if cfg.MaxCaptures <= 0 {
+		cfg.MaxCaptures = defaultWatchdogMaxCaptures
+	}
+	return &Watchdog{
+		logger: logger.Session("debug-server-watchdog"),
+		cfg:    cfg,
+	}
+}
+
+// WithWatchdog runs the watchdog alongside the debug server when used with Runner.
+func WithWatchdog(watchdog *Watchdog) Option {
+	return func(o *options) {
+		if watchdog != nil {
+			o.sidecars = append(o.sidecars, watchdog)
+		}
+	}
+}
+
+func (w *Watchdog) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	if w.cfg.Dir == "" {
+		return errors.New("watchdog capture directory is required")
+	}
+	if err := os.MkdirAll(w.cfg.Dir, 0700); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-signals:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+
+	close(ready)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			reasons := w.check(now)
+			if len(reasons) == 0 || now.Sub(w.lastCapture) < w.cfg.Cooldown {
+				continue
+			}
+			w.lastCapture = now
+			w.capture(ctx, now, reasons)
+		}
+	}
+}
+
+// check returns the thresholds that are currently exceeded.
+func (w *Watchdog) check(now time.Time) []string {
+	var reasons []string
+
+	if w.cfg.HeapInuseBytes > 0 {
+		inuse := readUint64Metric(heapObjectsMetric) + readUint64Metric(heapUnusedMetric)
+		if inuse > w.cfg.HeapInuseBytes {
+			reasons = append(reasons, "heap")
+		}
+	}
+
+	if w.cfg.Goroutines > 0 && runtime.NumGoroutine() > w.cfg.Goroutines {
+		reasons = append(reasons, "goroutines")
+	}
+
+	if w.cfg.CPUPercent > 0 {
+		if percent, ok := w.cpuPercent(now); ok {
+			if percent <= w.cfg.CPUPercent {
+				w.cpuHigh = time.Time{}
+			} else {
+				if w.cpuHigh.IsZero() {
+					w.cpuHigh = now
+				}
+				if now.Sub(w.cpuHigh) >= w.cfg.CPUSustainedFor {
+					reasons = append(reasons, "cpu")
+				}
+			}
+		}
+	}
+
+	return reasons
+}
+
+// cpuPercent returns the CPU usage since the previous call.
+func (w *Watchdog) cpuPercent(now time.Time) (float64, bool) {
+	cpu, err := processCPUTime()
+	if err != nil {
+		return 0, false
+	}
+	defer func() {
+		w.lastCPU = cpu
+		w.lastCPUAt = now
+	}()
+	if w.lastCPUAt.IsZero() {
+		return 0, false
+	}
+	wall := now.Sub(w.lastCPUAt)
+	if wall <= 0 {
+		return 0, false
+	}
+	return float64(cpu-w.lastCPU) / float64(wall) * 100, true
+}
+
+// capture prunes old captures and writes the profiles into a new directory.
+func (w *Watchdog) capture(ctx context.Context, now time.Time, reasons []string) {
+	name := watchdogCapturePrefix + now.UTC().Format("20060102T150405.000Z") + "-" + strings.Join(reasons, "-")
+	dir := filepath.Join(w.cfg.Dir, name)
+	logger := w.logger.Session("capture", lager.Data{"dir": dir, "reasons": reasons})
+	logger.Info("starting")
+
+	if err := w.makeCaptureDir(logger, dir); err != nil {
+		logger.Error("failed-to-create-dir", err)
+		return
+	}
+
+	trigger := map[string]interface{}{
+		"time":       now.UTC(),
+		"reasons":    reasons,
+		"goroutines": runtime.NumGoroutine(),
+		"heap_inuse": readUint64Metric(heapObjectsMetric) + readUint64Metric(heapUnusedMetric),
+	}
+	if err := writeJSONFile(filepath.Join(dir, "trigger.json"), trigger); err != nil {
+		logger.Error("failed-to-write-trigger", err)
+	}
+
+	for _, p := range []struct {
+		name  string
+		file  string
+		debug int
+	}{
+		{"heap", "heap.pb.gz", 0},
+		{"goroutine", "goroutine.txt", 2},
+	} {
+		if err := writeProfileFile(filepath.Join(dir, p.file), p.name, p.debug); err != nil {
+			logger.Error("failed-to-write-profile", err, lager.Data{"profile": p.name})
+		}
+	}
+
+	// The CPU profile waits for one taken through the debug server for up to its own
+	// duration, and shows up on /debug/captures while it runs.
+	cpu, err := captures.acquire(ctx, "watchdog "+name, "", []profileKind{cpuProfileKind}, w.cfg.CPUProfileDuration, w.cfg.CPUProfileDuration)
+	if err != nil {
+		logger.Error("failed-to-write-profile", err, lager.Data{"profile": "cpu"})
+	} else {
+		if err := writeCPUProfileFile(ctx, filepath.Join(dir, "cpu.pb.gz"), w.cfg.CPUProfileDuration); err != nil {
+			logger.Error("failed-to-write-profile", err, lager.Data{"profile": "cpu"})
+		}
+		captures.release(cpu)
+	}
+
+	logger.Info("finished")
+}
+
+// makeCaptureDir creates the directory of a new capture, making room first so that there
+// are never more than MaxCaptures on disk. When Dir is full, the oldest capture is renamed
+// to the new one and emptied instead of being removed before the new one is created, so
+// the number of captures does not dip while one is replaced either.
+func (w *Watchdog) makeCaptureDir(logger lager.Logger, dir string) error {
+	captures := w.prune(logger, w.cfg.MaxCaptures)
+	if len(captures) < w.cfg.MaxCaptures {
+		return os.Mkdir(dir, 0700)
+	}
+
+	if err := os.Rename(filepath.Join(w.cfg.Dir, captures[0]), dir); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prune removes the oldest captures until at most keep are left and returns the names of
+// the remaining ones, oldest first. Capture names sort by time.
+func (w *Watchdog) prune(logger lager.Logger, keep int) []string {
+	entries, err := os.ReadDir(w.cfg.Dir)
+	if err != nil {
+		logger.Error("failed-to-list-captures", err)
+		return nil
+	}
+
+	var captures []string
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), watchdogCapturePrefix) {
+			captures = append(captures, entry.Name())
+		}
+	}
+	sort.Strings(captures)
+
+	for len(captures) > keep {
+		if err := os.RemoveAll(filepath.Join(w.cfg.Dir, captures[0])); err != nil {
+			logger.Error("failed-to-remove-capture", err, lager.Data{"capture": captures[0]})
+		}
+		captures = captures[1:]
+	}
+	return captures
+}
+
+func writeProfileFile(path, name string, debug int) error {
+	profile := pprof.Lookup(name)
+	if profile == nil {
+		return fmt.Errorf("unknown profile %s", name)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return profile.WriteTo(f, debug)
+}
+
+// writeCPUProfileFile profiles the CPU for duration, or until ctx is done. The caller
+// holds the CPU profile slot of captures.
+func writeCPUProfileFile(ctx context.Context, path string, duration time.Duration) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := pprof.StartCPUProfile(f); err != nil {
+		return err
+	}
+	// Stopping early when the watchdog is shut down still leaves a valid, shorter profile.
+	_ = sleepContext(ctx, duration)
+	pprof.StopCPUProfile()
+	return nil
+}
+
+func writeJSONFile(path string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
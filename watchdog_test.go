@@ -0,0 +1,136 @@
+package debugserver_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	cf_debug_server "code.cloudfoundry.org/debugserver"
+	lager "code.cloudfoundry.org/lager/v3"
+	"github.com/tedsuo/ifrit"
+	ginkgomon "github.com/tedsuo/ifrit/ginkgomon_v2"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Watchdog", func() {
+	var (
+		logger lager.Logger
+		dir    string
+		cfg    cf_debug_server.WatchdogConfig
+
+		process ifrit.Process
+	)
+
+	BeforeEach(func() {
+		logger = lager.NewLogger("test")
+		dir = filepath.Join(GinkgoT().TempDir(), "captures")
+		cfg = cf_debug_server.WatchdogConfig{
+			Dir:                dir,
+			Interval:           20 * time.Millisecond,
+			Goroutines:         1,
+			CPUProfileDuration: 50 * time.Millisecond,
+			Cooldown:           time.Millisecond,
+			MaxCaptures:        2,
+		}
+	})
+
+	AfterEach(func() {
+		ginkgomon.Interrupt(process)
+	})
+
+	captures := func() []string {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil
+		}
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+
+	It("captures profiles when a threshold is exceeded", func() {
+		process = ginkgomon.Invoke(cf_debug_server.NewWatchdog(logger, cfg))
+
+		Eventually(captures).ShouldNot(BeEmpty())
+		capture := filepath.Join(dir, captures()[0])
+		Expect(capture).To(ContainSubstring("goroutines"))
+		Eventually(filepath.Join(capture, "cpu.pb.gz")).Should(BeAnExistingFile())
+		Expect(filepath.Join(capture, "heap.pb.gz")).To(BeAnExistingFile())
+		Expect(filepath.Join(capture, "goroutine.txt")).To(BeAnExistingFile())
+		Expect(filepath.Join(capture, "trigger.json")).To(BeAnExistingFile())
+	})
+
+	It("keeps only the newest captures", func() {
+		process = ginkgomon.Invoke(cf_debug_server.NewWatchdog(logger, cfg))
+
+		first := ""
+		Eventually(func() []string {
+			names := captures()
+			if first == "" && len(names) > 0 {
+				first = names[0]
+			}
+			return names
+		}).Should(HaveLen(2))
+		Eventually(captures).ShouldNot(ContainElement(first))
+		Consistently(captures, "200ms").Should(HaveLen(2))
+	})
+
+	It("waits for the cooldown between captures", func() {
+		cfg.Cooldown = time.Hour
+		process = ginkgomon.Invoke(cf_debug_server.NewWatchdog(logger, cfg))
+
+		Eventually(captures).Should(HaveLen(1))
+		Consistently(captures, "200ms").Should(HaveLen(1))
+	})
+
+	It("does not capture while thresholds are not exceeded", func() {
+		cfg.Goroutines = 1_000_000
+		cfg.HeapInuseBytes = 1 << 40
+		process = ginkgomon.Invoke(cf_debug_server.NewWatchdog(logger, cfg))
+
+		Consistently(captures, "200ms").Should(BeEmpty())
+	})
+
+	It("runs alongside the debug server", func() {
+		sink := lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.INFO)
+		process = ginkgomon.Invoke(cf_debug_server.Runner(address, sink, cf_debug_server.WithWatchdog(cf_debug_server.NewWatchdog(logger, cfg))))
+
+		Eventually(captures).ShouldNot(BeEmpty())
+	})
+
+	It("takes its CPU profile in the slot shared with the debug server", func() {
+		cfg.CPUProfileDuration = 2 * time.Second
+		cfg.Cooldown = time.Hour
+		sink := lager.NewReconfigurableSink(lager.NewWriterSink(io.Discard, lager.DEBUG), lager.INFO)
+		process = ginkgomon.Invoke(cf_debug_server.Runner(address, sink, cf_debug_server.WithWatchdog(cf_debug_server.NewWatchdog(logger, cfg))))
+
+		Eventually(func() string {
+			resp, err := http.Get(fmt.Sprintf("http://%s/debug/captures", address))
+			if err != nil {
+				return ""
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			return string(body)
+		}).Should(ContainSubstring(`"request":"watchdog watchdog-`))
+
+		resp, err := http.Get(fmt.Sprintf("http://%s/debug/pprof/profile?seconds=1", address))
+		Expect(err).NotTo(HaveOccurred())
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+	})
+
+	It("requires a capture directory", func() {
+		cfg.Dir = ""
+		p := ifrit.Invoke(cf_debug_server.NewWatchdog(logger, cfg))
+		Eventually(p.Wait()).Should(Receive(MatchError(ContainSubstring("directory is required"))))
+	})
+})